@@ -1,2 +1,8 @@
 #!/bin/sh
 gd -o minecraft_server
+gd -I src -o worldmap cmd/worldmap
+gd -I src -o worldstats cmd/worldstats
+gd -I src -o worldfsck cmd/worldfsck
+gd -I src -o worlddiff cmd/worlddiff
+gd -I src -o worldprune cmd/worldprune
+gd -I src -o worldconvert cmd/worldconvert
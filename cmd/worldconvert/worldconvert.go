@@ -0,0 +1,40 @@
+// Copies a world into another on-disk format: Alpha, McRegion or Anvil.  The
+// original is left as it was.
+
+package main
+
+import "minecraft/world"
+
+import "flag"
+import "fmt"
+import "os"
+
+var to = flag.String("to", "alpha", "format to convert to: alpha, mcregion or anvil")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worldconvert [flags] <world directory> <new world directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worldconvert: %s\n", err.String())
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+	}
+	format, err := world.ParseFormat(*to)
+	if err != nil {
+		fail(err)
+	}
+	report, err := world.Convert(flag.Arg(0), flag.Arg(1), format)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("converted %d chunks from %s to %s\n", report.Chunks, report.From, report.To)
+}
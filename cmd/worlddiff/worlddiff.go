@@ -0,0 +1,91 @@
+// Lists the chunks that differ between a backup of a world and the world as it is
+// now, and optionally puts some or all of them back the way the backup has them.
+
+package main
+
+import "minecraft/world"
+import "minecraft/world/diff"
+import "minecraft/error"
+
+import "flag"
+import "fmt"
+import "os"
+import "strings"
+
+var rollback = flag.String("rollback", "", "chunks to roll back, as space separated x,z pairs")
+var rollbackAll = flag.Bool("rollback-all", false, "roll back every chunk that differs")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worlddiff [flags] <backup directory> <world directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worlddiff: %s\n", err.String())
+	os.Exit(1)
+}
+
+func parseChunks(s string) (chunks []world.XZ, err os.Error) {
+	for _, pair := range strings.Fields(s) {
+		var x, z int32
+		if _, err = fmt.Sscanf(pair, "%d,%d", &x, &z); err != nil {
+			err = error.NewError(fmt.Sprintf("%q isn't an x,z pair", pair), nil)
+			return
+		}
+		chunks = append(chunks, world.MakeXZ(x, z))
+	}
+	return
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+	}
+	chunks, err := parseChunks(*rollback)
+	if err != nil {
+		fail(err)
+	}
+	rollingBack := len(chunks) > 0 || *rollbackAll
+
+	backup, err := world.OpenReadOnly(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	defer backup.Close()
+	var live *world.World
+	if rollingBack {
+		live, err = world.Open(flag.Arg(1))
+	} else {
+		live, err = world.OpenReadOnly(flag.Arg(1))
+	}
+	if err != nil {
+		fail(err)
+	}
+	defer live.Close()
+
+	d, err := diff.Compare(backup, live)
+	if err != nil {
+		fail(err)
+	}
+	for _, cd := range d.Chunks {
+		fmt.Printf("%-7s (%d, %d): %d blocks, %d tile entities\n", cd.Change, cd.X, cd.Z, cd.Blocks, cd.TileEntities)
+		if *rollbackAll {
+			chunks = append(chunks, world.MakeXZ(cd.X, cd.Z))
+		}
+	}
+	fmt.Printf("%d chunks differ, %d unchanged\n", len(d.Chunks), d.Unchanged)
+	if !rollingBack {
+		return
+	}
+
+	if err = diff.Rollback(live, backup, chunks); err != nil {
+		fail(err)
+	}
+	if err = live.Flush(); err != nil {
+		fail(err)
+	}
+	fmt.Printf("rolled back %d chunks\n", len(chunks))
+}
@@ -0,0 +1,51 @@
+// Checks a world for corrupt chunks and level.dat, and optionally moves the bad
+// chunks out of the way or regenerates them.  Exits with status 1 if anything was
+// wrong that didn't get fixed.
+
+package main
+
+import "minecraft/world"
+
+import "flag"
+import "fmt"
+import "os"
+
+var quarantine = flag.Bool("quarantine", false, "move bad chunks into the world's quarantine directory")
+var regenerate = flag.Bool("regenerate", false, "quarantine bad chunks and generate new ones in their place")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worldfsck [flags] <world directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worldfsck: %s\n", err.String())
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+	// only takes the world from whoever is playing it if there's something to fix.
+	report, err := world.VerifyDir(flag.Arg(0), &world.VerifyOptions{Quarantine: *quarantine, Regenerate: *regenerate})
+	if report != nil {
+		unfixed := 0
+		for _, p := range report.Problems {
+			fmt.Println(p)
+			if p.Fixed == "" {
+				unfixed++
+			}
+		}
+		fmt.Printf("%d chunks checked, %d problems, %d not fixed\n", report.Chunks, len(report.Problems), unfixed)
+		if err == nil && unfixed > 0 {
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+}
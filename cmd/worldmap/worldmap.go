@@ -0,0 +1,177 @@
+// Renders top-down maps of a world, one PNG per tile of 32x32 chunks.
+//
+// Only tiles whose chunks have changed (by LastUpdate) since the last run are
+// written again, so it is cheap to run regularly against a live world.
+
+package main
+
+import "minecraft/render"
+import "minecraft/world"
+
+import "bufio"
+import "flag"
+import "fmt"
+import "os"
+import "path"
+
+var mode = flag.String("mode", "surface", "what to draw: surface, caves or slice")
+var sliceY = flag.Int("y", 64, "height to cut at in slice mode")
+var whole = flag.Bool("world", false, "also render the whole world as world.png")
+var force = flag.Bool("force", false, "render every tile, changed or not")
+var chunkX = flag.Int("chunkx", 0, "with -chunk, the x of the chunk to render")
+var chunkZ = flag.Int("chunkz", 0, "with -chunk, the z of the chunk to render")
+var single = flag.Bool("chunk", false, "render just the chunk at (-chunkx, -chunkz)")
+
+const indexFile = "tiles.idx"
+
+// What a tile looked like when it was last rendered.
+type tileState struct {
+	chunks     int
+	lastUpdate int64
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worldmap [flags] <world directory> <output directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worldmap: %s\n", err.String())
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+	}
+	worlddir, outdir := flag.Arg(0), flag.Arg(1)
+
+	opts := &render.Options{SliceY: *sliceY}
+	switch *mode {
+	case "surface":
+		opts.Mode = render.Surface
+	case "caves":
+		opts.Mode = render.Caves
+	case "slice":
+		opts.Mode = render.Slice
+	default:
+		usage()
+	}
+
+	// read-only, so a running server doesn't notice us.
+	w, err := world.OpenReadOnly(worlddir)
+	if err != nil {
+		fail(err)
+	}
+	defer w.Close()
+	if err = os.MkdirAll(outdir, 0755); err != nil {
+		fail(err)
+	}
+
+	if *single {
+		x, z := int32(*chunkX), int32(*chunkZ)
+		if err = w.LoadChunk(x, z); err != nil {
+			fail(err)
+		}
+		file := path.Join(outdir, fmt.Sprintf("chunk.%d.%d.png", x, z))
+		if err = render.WritePNG(file, render.Chunk(&w.Chunk(x, z).Level, opts)); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	var all []world.XZ
+	tiles := make(map[world.XZ][]world.XZ)
+	for _, xz := range w.ChunkCoords() {
+		tx, tz := render.TileOf(xz)
+		tile := world.MakeXZ(tx, tz)
+		tiles[tile] = append(tiles[tile], xz)
+		all = append(all, xz)
+	}
+
+	index := make(map[world.XZ]tileState)
+	if !*force {
+		index = readIndex(path.Join(outdir, indexFile))
+	}
+	rendered := 0
+	for tile, coords := range tiles {
+		tx, tz := tile.X(), tile.Z()
+		// reading LastUpdate is much cheaper than drawing, so check before rendering.
+		if old, ok := index[tile]; ok && old.chunks == len(coords) && old.lastUpdate >= newestUpdate(w, coords) {
+			continue
+		}
+		img, lastUpdate, err := render.Tile(w, tx, tz, coords, opts)
+		if err != nil {
+			// draw what we could; one bad chunk shouldn't leave a hole in the map.
+			fmt.Fprintf(os.Stderr, "worldmap: %s\n", err.String())
+		}
+		file := path.Join(outdir, fmt.Sprintf("tile.%d.%d.png", tx, tz))
+		if err = render.WritePNG(file, img); err != nil {
+			fail(err)
+		}
+		index[tile] = tileState{len(coords), lastUpdate}
+		rendered++
+	}
+	if err = writeIndex(path.Join(outdir, indexFile), index); err != nil {
+		fail(err)
+	}
+	fmt.Printf("%d chunks, %d tiles, %d rendered\n", len(all), len(tiles), rendered)
+
+	if *whole {
+		img, err := render.World(w, all, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "worldmap: %s\n", err.String())
+		}
+		if err = render.WritePNG(path.Join(outdir, "world.png"), img); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// Returns the newest LastUpdate of the chunks at coords.  Chunks that can't be read
+// are left out here; rendering reports them.
+func newestUpdate(w *world.World, coords []world.XZ) (newest int64) {
+	for _, xz := range coords {
+		if t, err := w.ChunkLastUpdate(xz.X(), xz.Z()); err == nil && t > newest {
+			newest = t
+		}
+	}
+	return
+}
+
+// The index has a line per tile: its x, z, how many chunks it had and their newest LastUpdate.
+// A missing or unreadable index just means everything gets rendered.
+func readIndex(file string) map[world.XZ]tileState {
+	index := make(map[world.XZ]tileState)
+	f, err := os.Open(file, os.O_RDONLY, 0000)
+	if err != nil {
+		return index
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		var tx, tz int32
+		var state tileState
+		if _, err := fmt.Fscanln(r, &tx, &tz, &state.chunks, &state.lastUpdate); err != nil {
+			break
+		}
+		index[world.MakeXZ(tx, tz)] = state
+	}
+	return index
+}
+
+func writeIndex(file string, index map[world.XZ]tileState) (err os.Error) {
+	f, err := os.Open(file, os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for tile, state := range index {
+		fmt.Fprintf(w, "%d %d %d %d\n", tile.X(), tile.Z(), state.chunks, state.lastUpdate)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+// Removes chunks that were explored once and never touched, so worlds don't grow
+// without end.  Removed chunks are generated afresh if anyone goes back.
+
+package main
+
+import "minecraft/world"
+import "minecraft/error"
+
+import "flag"
+import "fmt"
+import "os"
+import "strings"
+
+var keep = flag.String("keep", "", "areas to keep, as space separated x,z,radius chunk triples; everything else is removed")
+var unedited = flag.Bool("unedited", false, "remove chunks nobody has changed since they were generated, wherever they are")
+var dryRun = flag.Bool("n", false, "only report what would be removed")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worldprune [flags] <world directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worldprune: %s\n", err.String())
+	os.Exit(1)
+}
+
+func parseAreas(s string) (areas []world.KeepArea, err os.Error) {
+	for _, triple := range strings.Fields(s) {
+		var a world.KeepArea
+		if _, err = fmt.Sscanf(triple, "%d,%d,%d", &a.X, &a.Z, &a.Radius); err != nil {
+			err = error.NewError(fmt.Sprintf("%q isn't an x,z,radius triple", triple), nil)
+			return
+		}
+		areas = append(areas, a)
+	}
+	return
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+	areas, err := parseAreas(*keep)
+	if err != nil {
+		fail(err)
+	}
+	if len(areas) == 0 && !*unedited {
+		fmt.Fprintf(os.Stderr, "worldprune: nothing to do without -keep or -unedited\n")
+		usage()
+	}
+
+	var w *world.World
+	if *dryRun {
+		w, err = world.OpenReadOnly(flag.Arg(0))
+	} else {
+		w, err = world.Open(flag.Arg(0))
+	}
+	if err != nil {
+		fail(err)
+	}
+	defer w.Close()
+
+	report, err := w.Prune(&world.PruneOptions{Keep: areas, Unedited: *unedited, DryRun: *dryRun})
+	if report != nil {
+		verb := "removed"
+		if *dryRun {
+			verb = "would remove"
+		}
+		for _, xz := range report.Removed {
+			fmt.Printf("%s (%d, %d)\n", verb, xz.X(), xz.Z())
+		}
+		fmt.Printf("%s %d of %d chunks, %d bytes\n", verb, len(report.Removed), report.Chunks, report.Freed)
+	}
+	if err != nil {
+		w.Close()
+		fail(err)
+	}
+}
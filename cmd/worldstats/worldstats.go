@@ -0,0 +1,149 @@
+// Prints statistics about a world: chunk counts and sizes, blocks, ores by
+// height and entities.
+
+package main
+
+import "minecraft/registry"
+import "minecraft/world"
+import "minecraft/world/stats"
+
+import "flag"
+import "fmt"
+import "json"
+import "os"
+import "sort"
+
+var asJSON = flag.Bool("json", false, "print JSON instead of tables")
+var workers = flag.Int("workers", 4, "how many chunks to count at once")
+var quiet = flag.Bool("q", false, "don't report progress")
+
+// Ores are summarised in bands this many blocks high.
+const oreBand = 8
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: worldstats [flags] <world directory>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err os.Error) {
+	fmt.Fprintf(os.Stderr, "worldstats: %s\n", err.String())
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usage()
+	}
+	w, err := world.OpenReadOnly(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	defer w.Close()
+
+	progress := make(chan stats.Progress)
+	reported := make(chan bool)
+	go func() {
+		for p := range progress {
+			if !*quiet && (p.Done%100 == 0 || p.Done == p.Total) {
+				fmt.Fprintf(os.Stderr, "\r%d/%d chunks", p.Done, p.Total)
+			}
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+		reported <- true
+	}()
+	s, err := stats.Scan(w, *workers, progress)
+	if err != nil {
+		fail(err)
+	}
+	<-reported
+
+	if *asJSON {
+		b, err := json.MarshalIndent(named(s), "", "\t")
+		if err != nil {
+			fail(err)
+		}
+		os.Stdout.Write(b)
+		fmt.Println()
+		return
+	}
+	printTables(s)
+}
+
+// What -json prints: the same as Stats, but with blocks counted by name.
+type namedStats struct {
+	Chunks                             int
+	Bytes, SmallestChunk, LargestChunk int64
+	Blocks                             map[string]int64
+	Ores                               map[string][]int64
+	Entities                           map[string]int
+	TileEntities                       map[string]int
+	Failed                             []world.XZ
+}
+
+func named(s *stats.Stats) *namedStats {
+	n := &namedStats{s.Chunks, s.Bytes, s.SmallestChunk, s.LargestChunk, make(map[string]int64),
+		s.Ores, s.Entities, s.TileEntities, s.Failed}
+	for id, count := range s.Blocks {
+		if count > 0 {
+			n.Blocks[registry.GetBlock(byte(id)).Name] = count
+		}
+	}
+	return n
+}
+
+func printTables(s *stats.Stats) {
+	fmt.Printf("chunks: %d (%d unreadable)\n", s.Chunks, len(s.Failed))
+	if s.Chunks > 0 {
+		fmt.Printf("size:   %d bytes, %d to %d per chunk, %d on average\n",
+			s.Bytes, s.SmallestChunk, s.LargestChunk, s.Bytes/int64(s.Chunks))
+	}
+
+	fmt.Printf("\n%-20s %14s\n", "block", "count")
+	for id, n := range s.Blocks {
+		if n > 0 {
+			fmt.Printf("%-20s %14d\n", registry.GetBlock(byte(id)).Name, n)
+		}
+	}
+
+	var ores []string
+	for name := range s.Ores {
+		ores = append(ores, name)
+	}
+	sort.SortStrings(ores)
+	fmt.Printf("\n%-8s", "y")
+	for _, name := range ores {
+		fmt.Printf(" %10s", name)
+	}
+	fmt.Println()
+	for y := 0; y < world.ChunkSizeY; y += oreBand {
+		fmt.Printf("%3d-%-4d", y, y+oreBand-1)
+		for _, name := range ores {
+			var n int64
+			for _, c := range s.Ores[name][y : y+oreBand] {
+				n += c
+			}
+			fmt.Printf(" %10d", n)
+		}
+		fmt.Println()
+	}
+
+	printCounts("entity", s.Entities)
+	printCounts("tile entity", s.TileEntities)
+}
+
+func printCounts(what string, counts map[string]int) {
+	var ids []string
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.SortStrings(ids)
+	fmt.Printf("\n%-16s %10s\n", what, "count")
+	for _, id := range ids {
+		fmt.Printf("%-16s %10d\n", id, counts[id])
+	}
+}
@@ -23,5 +23,18 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Printf("Press enter to stop.\n")
-	fmt.Scanln()
+	enter := make(chan bool)
+	go func() {
+		fmt.Scanln()
+		enter <- true
+	}()
+	select {
+	case <-enter:
+	case <-world.LockLost():
+		fmt.Printf("another process has opened the world; stopping.\n")
+	}
+	if err = world.Close(); err != nil {
+		fmt.Printf("can't close world; err=%s\n", err.String())
+		os.Exit(1)
+	}
 }
@@ -1,5 +1,10 @@
 package world
 
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
 // encoding/decoding for minecraft-style base36
 var b36chars = []byte{
 	'0', '1', '2', '3', '4', '5', '6', '7',
@@ -32,3 +37,45 @@ func int32ToBase36String(i int32) string {
 	}
 	return string(str[ix:])
 }
+
+func base36Digit(c byte) (d int32, ok bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int32(c - '0'), true
+	case c >= 'a' && c <= 'z':
+		return int32(c-'a') + 10, true
+	}
+	return
+}
+
+// The inverse of int32ToBase36String.
+func base36StringToInt32(s string) (i int32, err os.Error) {
+	var neg bool
+	digits := s
+	if len(digits) > 0 && digits[0] == '-' {
+		neg = true
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || len(digits) > 6 {
+		err = error.NewError(fmt.Sprintf("%q is not a base36 number", s), nil)
+		return
+	}
+	var n int64
+	for j := 0; j < len(digits); j++ {
+		d, ok := base36Digit(digits[j])
+		if !ok {
+			err = error.NewError(fmt.Sprintf("%q is not a base36 number", s), nil)
+			return
+		}
+		n = n*36 + int64(d)
+	}
+	if neg {
+		n = -n
+	}
+	if n != int64(int32(n)) {
+		err = error.NewError(fmt.Sprintf("%q doesn't fit in 32 bits", s), nil)
+		return
+	}
+	i = int32(n)
+	return
+}
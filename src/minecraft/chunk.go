@@ -0,0 +1,142 @@
+package world
+
+// Block storage helpers.  See http://www.minecraftwiki.net/wiki/Alpha_Level_Format/Chunk_File_Format
+// Blocks are stored column by column: index = y + z*128 + x*128*16.  Data, SkyLight and
+// BlockLight use the same ordering, but pack two blocks per byte (even index in the
+// low nibble).  HeightMap is indexed by z*16 + x.
+
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+const (
+	ChunkSizeX = 16
+	ChunkSizeY = 128
+	ChunkSizeZ = 16
+
+	chunkBlocks  = ChunkSizeX * ChunkSizeY * ChunkSizeZ
+	chunkNibbles = chunkBlocks / 2
+	chunkColumns = ChunkSizeX * ChunkSizeZ
+)
+
+// Allocates an empty (all air, all dark) level for the chunk at (x, z).
+func newLevel(x int32, z int32) Level {
+	return Level{
+		Blocks:       make([]byte, chunkBlocks),
+		Data:         make([]byte, chunkNibbles),
+		SkyLight:     make([]byte, chunkNibbles),
+		HeightMap:    make([]byte, chunkColumns),
+		BlockLight:   make([]byte, chunkNibbles),
+		Entities:     []Entity{},
+		TileEntities: []TileEntity{},
+		XPos:         x,
+		ZPos:         z,
+	}
+}
+
+// Everything that indexes into a level assumes its arrays are full size, so a
+// chunk with short ones is refused rather than panicking somewhere later.
+func (l *Level) checkArrays() os.Error {
+	arrays := []struct {
+		name   string
+		b      []byte
+		length int
+	}{
+		{"Blocks", l.Blocks, chunkBlocks},
+		{"Data", l.Data, chunkNibbles},
+		{"SkyLight", l.SkyLight, chunkNibbles},
+		{"BlockLight", l.BlockLight, chunkNibbles},
+		{"HeightMap", l.HeightMap, chunkColumns},
+	}
+	for _, a := range arrays {
+		if len(a.b) != a.length {
+			return error.NewError(fmt.Sprintf("expected %s to be %d bytes, got %d", a.name, a.length, len(a.b)), nil)
+		}
+	}
+	return nil
+}
+
+func blockIndex(x int, y int, z int) int {
+	return y + z*ChunkSizeY + x*ChunkSizeY*ChunkSizeZ
+}
+
+func inChunk(x int, y int, z int) bool {
+	return x >= 0 && x < ChunkSizeX && y >= 0 && y < ChunkSizeY && z >= 0 && z < ChunkSizeZ
+}
+
+func getNibble(arr []byte, i int) byte {
+	if i&1 == 0 {
+		return arr[i>>1] & 0x0f
+	}
+	return arr[i>>1] >> 4
+}
+
+func setNibble(arr []byte, i int, v byte) {
+	if i&1 == 0 {
+		arr[i>>1] = arr[i>>1]&0xf0 | v&0x0f
+	} else {
+		arr[i>>1] = arr[i>>1]&0x0f | v<<4
+	}
+}
+
+// Block coordinates are relative to the chunk: 0 <= x, z < 16 and 0 <= y < 128.
+
+func (l *Level) Block(x int, y int, z int) byte {
+	return l.Blocks[blockIndex(x, y, z)]
+}
+
+func (l *Level) SetBlock(x int, y int, z int, id byte) {
+	l.Blocks[blockIndex(x, y, z)] = id
+}
+
+func (l *Level) BlockData(x int, y int, z int) byte {
+	return getNibble(l.Data, blockIndex(x, y, z))
+}
+
+func (l *Level) SetBlockData(x int, y int, z int, data byte) {
+	setNibble(l.Data, blockIndex(x, y, z), data)
+}
+
+func (l *Level) SkyLightAt(x int, y int, z int) byte {
+	return getNibble(l.SkyLight, blockIndex(x, y, z))
+}
+
+func (l *Level) SetSkyLight(x int, y int, z int, light byte) {
+	setNibble(l.SkyLight, blockIndex(x, y, z), light)
+}
+
+func (l *Level) BlockLightAt(x int, y int, z int) byte {
+	return getNibble(l.BlockLight, blockIndex(x, y, z))
+}
+
+func (l *Level) SetBlockLight(x int, y int, z int, light byte) {
+	setNibble(l.BlockLight, blockIndex(x, y, z), light)
+}
+
+// Returns the lowest y at which the column (x, z) sees the sky.
+func (l *Level) Height(x int, z int) int {
+	return int(l.HeightMap[z*ChunkSizeX+x])
+}
+
+func (l *Level) setHeight(x int, z int, y int) {
+	l.HeightMap[z*ChunkSizeX+x] = byte(y)
+}
+
+// Recomputes HeightMap from Blocks.
+func (l *Level) computeHeightMap() {
+	for x := 0; x < ChunkSizeX; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			l.computeHeight(x, z)
+		}
+	}
+}
+
+func (l *Level) computeHeight(x int, z int) {
+	y := ChunkSizeY
+	for y > 0 && registry.GetBlock(l.Block(x, y-1, z)).Opacity == 0 {
+		y--
+	}
+	l.setHeight(x, z, y)
+}
@@ -0,0 +1,207 @@
+package world
+
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// A compoundReader pulls typed values out of an nbt compound without panicking on
+// the unexpected.  Missing keys read as zero values; keys of the wrong type are
+// remembered as an error.  Whatever keys weren't asked for can be collected with
+// extra(), so they can be written back out untouched.
+type compoundReader struct {
+	c    map[string]interface{}
+	used map[string]bool
+	err  os.Error
+}
+
+func newCompoundReader(c map[string]interface{}) *compoundReader {
+	return &compoundReader{c: c, used: make(map[string]bool)}
+}
+
+func (r *compoundReader) get(key string) (v interface{}, ok bool) {
+	r.used[key] = true
+	v, ok = r.c[key]
+	return
+}
+
+func (r *compoundReader) wrongType(key string, expected string, v interface{}) {
+	if r.err == nil {
+		r.err = error.NewError(fmt.Sprintf("expected %s to be %s, got %T", key, expected, v), nil)
+	}
+}
+
+func (r *compoundReader) has(key string) bool {
+	_, ok := r.c[key]
+	return ok
+}
+
+func (r *compoundReader) int8(key string) (i int8) {
+	if v, ok := r.get(key); ok {
+		if i, ok = v.(int8); !ok {
+			r.wrongType(key, "a byte", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) int16(key string) (i int16) {
+	if v, ok := r.get(key); ok {
+		if i, ok = v.(int16); !ok {
+			r.wrongType(key, "a short", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) int32(key string) (i int32) {
+	if v, ok := r.get(key); ok {
+		if i, ok = v.(int32); !ok {
+			r.wrongType(key, "an int", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) int64(key string) (i int64) {
+	if v, ok := r.get(key); ok {
+		if i, ok = v.(int64); !ok {
+			r.wrongType(key, "a long", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) float32(key string) (f float32) {
+	if v, ok := r.get(key); ok {
+		if f, ok = v.(float32); !ok {
+			r.wrongType(key, "a float", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) float64(key string) (f float64) {
+	if v, ok := r.get(key); ok {
+		if f, ok = v.(float64); !ok {
+			r.wrongType(key, "a double", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) bytes(key string) (b []byte) {
+	if v, ok := r.get(key); ok {
+		if b, ok = v.([]byte); !ok {
+			r.wrongType(key, "a byte array", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) string(key string) (s string) {
+	if v, ok := r.get(key); ok {
+		if s, ok = v.(string); !ok {
+			r.wrongType(key, "a string", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) list(key string) (l []interface{}) {
+	if v, ok := r.get(key); ok {
+		if l, ok = v.([]interface{}); !ok {
+			r.wrongType(key, "a list", v)
+		}
+	}
+	return
+}
+
+func (r *compoundReader) compound(key string) (c map[string]interface{}) {
+	if v, ok := r.get(key); ok {
+		if c, ok = v.(map[string]interface{}); !ok {
+			r.wrongType(key, "a compound", v)
+		}
+	}
+	return
+}
+
+// Reads a list of n doubles, like Pos and Motion.
+func (r *compoundReader) float64s(key string, n int) (f []float64) {
+	f = make([]float64, n)
+	l := r.list(key)
+	if l == nil {
+		return
+	}
+	if len(l) != n {
+		r.wrongType(key, fmt.Sprint(n, " doubles"), l)
+		return
+	}
+	for i, v := range l {
+		var ok bool
+		if f[i], ok = v.(float64); !ok {
+			r.wrongType(key, fmt.Sprint(n, " doubles"), l)
+			return
+		}
+	}
+	return
+}
+
+// Reads a list of n floats, like Rotation.
+func (r *compoundReader) float32s(key string, n int) (f []float32) {
+	f = make([]float32, n)
+	l := r.list(key)
+	if l == nil {
+		return
+	}
+	if len(l) != n {
+		r.wrongType(key, fmt.Sprint(n, " floats"), l)
+		return
+	}
+	for i, v := range l {
+		var ok bool
+		if f[i], ok = v.(float32); !ok {
+			r.wrongType(key, fmt.Sprint(n, " floats"), l)
+			return
+		}
+	}
+	return
+}
+
+// Returns every key that hasn't been read, or nil if there aren't any.
+func (r *compoundReader) extra() (extra map[string]interface{}) {
+	for k, v := range r.c {
+		if !r.used[k] {
+			if extra == nil {
+				extra = make(map[string]interface{})
+			}
+			extra[k] = v
+		}
+	}
+	return
+}
+
+// Starts a compound for writing, seeded with the keys that were preserved on read.
+func newCompound(extra map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(extra))
+	for k, v := range extra {
+		c[k] = v
+	}
+	return c
+}
+
+func doubleList(f ...float64) []interface{} {
+	l := make([]interface{}, len(f))
+	for i, v := range f {
+		l[i] = v
+	}
+	return l
+}
+
+func floatList(f ...float32) []interface{} {
+	l := make([]interface{}, len(f))
+	for i, v := range f {
+		l[i] = v
+	}
+	return l
+}
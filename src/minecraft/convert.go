@@ -0,0 +1,494 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+import "path"
+import "sort"
+import "strings"
+
+// The ways Minecraft has laid worlds out on disk.  World itself only reads and
+// writes Alpha; Convert moves worlds between them.
+type Format int
+
+const (
+	// a gzipped file per chunk, in base36 directories.
+	Alpha Format = iota
+	// the same chunks, 32 by 32 to a region file.
+	McRegion
+	// region files again, with chunks split into 16 block high sections, and
+	// room for worlds 256 blocks high.
+	Anvil
+)
+
+// level.dat's version for the region formats; Alpha worlds don't have one.
+const (
+	mcregionVersion = 19132
+	anvilVersion    = 19133
+)
+
+// Anvil sections are 16 blocks high.  Alpha chunks fit in the first 8.
+const (
+	sectionHeight   = 16
+	alphaSections   = ChunkSizeY / sectionHeight
+	sectionBlocks   = ChunkSizeX * sectionHeight * ChunkSizeZ
+	anvilMaxSection = 16
+)
+
+func (f Format) String() string {
+	switch f {
+	case Alpha:
+		return "Alpha"
+	case McRegion:
+		return "McRegion"
+	case Anvil:
+		return "Anvil"
+	}
+	return fmt.Sprint("Format(", int(f), ")")
+}
+
+// Parses a format's name, in any case.
+func ParseFormat(s string) (f Format, err os.Error) {
+	for f = Alpha; f <= Anvil; f++ {
+		if strings.ToLower(s) == strings.ToLower(f.String()) {
+			return
+		}
+	}
+	return 0, error.NewError(fmt.Sprintf("%q is not a world format", s), nil)
+}
+
+// region files' extension.
+func (f Format) ext() string {
+	if f == Anvil {
+		return "mca"
+	}
+	return "mcr"
+}
+
+// Works out the format of the world in dir from level.dat's version.
+func DetectFormat(dir string) (f Format, err os.Error) {
+	_, level, err := nbt.Load(path.Join(dir, leveldat))
+	if err != nil {
+		err = error.NewError("could not read level", err)
+		return
+	}
+	data, _ := level["Data"].(map[string]interface{})
+	if data == nil {
+		return 0, error.NewError("level has no Data", nil)
+	}
+	version, ok := data["version"].(int32)
+	switch {
+	case !ok:
+		f = Alpha
+	case version == mcregionVersion:
+		f = McRegion
+	case version == anvilVersion:
+		f = Anvil
+	default:
+		err = error.NewError(fmt.Sprint("unknown level version ", version), nil)
+	}
+	return
+}
+
+// Returns the coordinates of every chunk saved in the world in dir.
+func formatChunkCoords(dir string, f Format) []XZ {
+	if f == Alpha {
+		return alphaChunkCoords(dir)
+	}
+	return regionChunkCoords(dir, f.ext())
+}
+
+// Counts the chunks saved in the world in dir.
+func countChunks(dir string, f Format) int {
+	return len(formatChunkCoords(dir, f))
+}
+
+type ConvertReport struct {
+	From, To Format
+	// chunks converted.
+	Chunks int
+}
+
+// Sorts chunks so the ones in the same region file are together.
+type byRegion []XZ
+
+func (r byRegion) Len() int {
+	return len(r)
+}
+
+func (r byRegion) Less(i int, j int) bool {
+	a, b := r[i], r[j]
+	if a.X()>>5 != b.X()>>5 {
+		return a.X()>>5 < b.X()>>5
+	}
+	if a.Z()>>5 != b.Z()>>5 {
+		return a.Z()>>5 < b.Z()>>5
+	}
+	return regionIndex(a.X(), a.Z()) < regionIndex(b.X(), b.Z())
+}
+
+func (r byRegion) Swap(i int, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+// Reads chunks from a world in any format, keeping the region file it last read
+// from open.
+type chunkReader struct {
+	dir    string
+	format Format
+	region *regionReader
+	// the file region was opened from.
+	file string
+}
+
+func (r *chunkReader) read(x int32, z int32) (payload map[string]interface{}, err os.Error) {
+	if r.format == Alpha {
+		_, payload, err = nbt.Load(alphaChunkPath(r.dir, x, z))
+		return
+	}
+	file := regionPath(r.dir, x, z, r.format.ext())
+	if r.region == nil || r.file != file {
+		r.Close()
+		if r.region, err = openRegion(file); err != nil {
+			return
+		}
+		r.file = file
+	}
+	return r.region.read(x, z)
+}
+
+func (r *chunkReader) Close() {
+	if r.region != nil {
+		r.region.Close()
+		r.region = nil
+	}
+}
+
+// Writes chunks into a world in any format.  Region files are written whole, so
+// chunks must come a region at a time.
+type chunkWriter struct {
+	dir    string
+	format Format
+	region *regionWriter
+	file   string
+}
+
+func (w *chunkWriter) write(x int32, z int32, payload map[string]interface{}) (err os.Error) {
+	if w.format == Alpha {
+		chunkPath := alphaChunkPath(w.dir, x, z)
+		dir, _ := path.Split(chunkPath)
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+		return nbt.Save(chunkPath, "", payload)
+	}
+	file := regionPath(w.dir, x, z, w.format.ext())
+	if w.region == nil || w.file != file {
+		if err = w.Close(); err != nil {
+			return
+		}
+		if w.region, err = createRegion(file); err != nil {
+			return
+		}
+		w.file = file
+	}
+	return w.region.write(x, z, payload)
+}
+
+func (w *chunkWriter) Close() (err os.Error) {
+	if w.region != nil {
+		err = w.region.Close()
+		w.region = nil
+	}
+	return
+}
+
+// Stops without putting the region being written in place.
+func (w *chunkWriter) abandon() {
+	if w.region != nil {
+		w.region.abandon()
+		w.region = nil
+	}
+}
+
+// Copies the world in src into dest, which must not already hold a world, in
+// format to.  The source's format comes from its level.dat.  Chunks are read and
+// written one at a time; entities, tile entities and anything else in a chunk are
+// carried over untouched.  Anvil chunks with blocks above y=127, or with block ids
+// over 255, can't be converted back to the older formats, and stop the conversion.
+// level.dat and the players directory are copied, and the copy is checked to have
+// as many chunks as the original.  The source is only read, so it is best not to
+// convert a world while it is being played.  If the conversion fails, dest is left
+// with whatever chunks were finished but no level.dat, so it can't be opened by
+// mistake, and converting into it again starts over.
+func Convert(src string, dest string, to Format) (report *ConvertReport, err os.Error) {
+	from, err := DetectFormat(src)
+	if err != nil {
+		return
+	}
+	if exists(path.Join(dest, leveldat)) {
+		return nil, error.NewError(fmt.Sprint("there is already a world in ", dest), nil)
+	}
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return nil, error.NewError("could not create world directory", err)
+	}
+	if to != Alpha {
+		if err = os.MkdirAll(path.Join(dest, regiondir), 0755); err != nil {
+			return nil, error.NewError("could not create region directory", err)
+		}
+	}
+
+	coords := formatChunkCoords(src, from)
+	sort.Sort(byRegion(coords))
+	report = &ConvertReport{From: from, To: to}
+	r := &chunkReader{dir: src, format: from}
+	defer r.Close()
+	w := &chunkWriter{dir: dest, format: to}
+	for _, xz := range coords {
+		x, z := xz.X(), xz.Z()
+		payload, e := r.read(x, z)
+		if e == nil {
+			e = convertChunk(payload, from, to)
+		}
+		if e == nil {
+			e = w.write(x, z, payload)
+		}
+		if e != nil {
+			w.abandon()
+			err = error.NewError(fmt.Sprintf("could not convert chunk (%d, %d)", x, z), e)
+			return
+		}
+		report.Chunks++
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	// checked before level.dat is written, so a short copy can't be opened by mistake.
+	if n := countChunks(dest, to); n != len(coords) {
+		err = error.NewError(fmt.Sprintf("converted world has %d chunks, expected %d", n, len(coords)), nil)
+		return
+	}
+
+	if err = convertLevelDat(src, dest, to); err != nil {
+		return
+	}
+	if err = copyPlayers(src, dest); err != nil {
+		return
+	}
+	return report, writeSessionLock(dest)
+}
+
+func convertLevelDat(src string, dest string, to Format) (err os.Error) {
+	name, level, err := nbt.Load(path.Join(src, leveldat))
+	if err != nil {
+		return error.NewError("could not read level", err)
+	}
+	data, _ := level["Data"].(map[string]interface{})
+	if data == nil {
+		return error.NewError("level has no Data", nil)
+	}
+	switch to {
+	case Alpha:
+		data["version"] = nil, false
+	case McRegion:
+		data["version"] = int32(mcregionVersion)
+	case Anvil:
+		data["version"] = int32(anvilVersion)
+	}
+	if err = nbt.Save(path.Join(dest, leveldat), name, level); err != nil {
+		return error.NewError("could not write level", err)
+	}
+	return
+}
+
+func copyPlayers(src string, dest string) (err os.Error) {
+	from := path.Join(src, playersdir)
+	if !exists(from) {
+		return
+	}
+	if err = copyTree(from, path.Join(dest, playersdir), ""); err != nil {
+		return error.NewError("could not copy players", err)
+	}
+	return
+}
+
+// Rewrites a chunk read in format from as format to would have it.  Alpha and
+// McRegion chunks are the same; they just live in different files.
+func convertChunk(payload map[string]interface{}, from Format, to Format) (err os.Error) {
+	level, _ := payload["Level"].(map[string]interface{})
+	if level == nil {
+		return error.NewError("chunk has no Level", nil)
+	}
+	switch {
+	case from != Anvil && to == Anvil:
+		err = toSections(level)
+	case from == Anvil && to != Anvil:
+		err = fromSections(level)
+	}
+	return
+}
+
+// Splits an Alpha chunk's arrays into Anvil sections.  Sections with nothing but
+// air are left out, as Minecraft does.
+func toSections(level map[string]interface{}) (err os.Error) {
+	r := newCompoundReader(level)
+	l := Level{
+		Blocks:     r.bytes("Blocks"),
+		Data:       r.bytes("Data"),
+		SkyLight:   r.bytes("SkyLight"),
+		BlockLight: r.bytes("BlockLight"),
+		HeightMap:  r.bytes("HeightMap"),
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if err = l.checkArrays(); err != nil {
+		return
+	}
+	var sections []interface{}
+	for sy := 0; sy < alphaSections; sy++ {
+		blocks := make([]byte, sectionBlocks)
+		data := make([]byte, sectionBlocks/2)
+		sky := make([]byte, sectionBlocks/2)
+		light := make([]byte, sectionBlocks/2)
+		empty := true
+		for x := 0; x < ChunkSizeX; x++ {
+			for z := 0; z < ChunkSizeZ; z++ {
+				for y := 0; y < sectionHeight; y++ {
+					i, j := blockIndex(x, sy*sectionHeight+y, z), sectionIndex(x, y, z)
+					blocks[j] = l.Blocks[i]
+					if blocks[j] != 0 {
+						empty = false
+					}
+					setNibble(data, j, getNibble(l.Data, i))
+					setNibble(sky, j, getNibble(l.SkyLight, i))
+					setNibble(light, j, getNibble(l.BlockLight, i))
+				}
+			}
+		}
+		if empty {
+			continue
+		}
+		sections = append(sections, map[string]interface{}{
+			"Y":          int8(sy),
+			"Blocks":     blocks,
+			"Data":       data,
+			"SkyLight":   sky,
+			"BlockLight": light,
+		})
+	}
+	heights := make([]int32, len(l.HeightMap))
+	for i, h := range l.HeightMap {
+		heights[i] = int32(h)
+	}
+	for _, name := range []string{"Blocks", "Data", "SkyLight", "BlockLight"} {
+		level[name] = nil, false
+	}
+	level["Sections"] = sections
+	level["HeightMap"] = heights
+	return
+}
+
+// Anvil sections store blocks y, then z, then x.
+func sectionIndex(x int, y int, z int) int {
+	return y*ChunkSizeX*ChunkSizeZ + z*ChunkSizeX + x
+}
+
+// Puts an Anvil chunk's sections back together as Alpha arrays.  Missing sections
+// are air, lit by the sky only above the height map; a missing section under an
+// overhang is dark, not open to the sky.
+func fromSections(level map[string]interface{}) (err os.Error) {
+	r := newCompoundReader(level)
+	if !r.has("Sections") {
+		return error.NewError("chunk has no Sections", nil)
+	}
+	sections := r.list("Sections")
+	if r.err != nil {
+		return r.err
+	}
+	l := newLevel(0, 0)
+	var present [alphaSections]bool
+	for _, s := range sections {
+		c, ok := s.(map[string]interface{})
+		if !ok {
+			return error.NewError(fmt.Sprintf("expected a section compound, got %T", s), nil)
+		}
+		sr := newCompoundReader(c)
+		sy := int(sr.int8("Y"))
+		blocks := sr.bytes("Blocks")
+		data, sky, light := sr.bytes("Data"), sr.bytes("SkyLight"), sr.bytes("BlockLight")
+		if sr.err != nil {
+			return sr.err
+		}
+		if len(blocks) != sectionBlocks || len(data) != sectionBlocks/2 || len(sky) != sectionBlocks/2 || len(light) != sectionBlocks/2 {
+			return error.NewError(fmt.Sprint("section ", sy, " has arrays of the wrong size"), nil)
+		}
+		if add, ok := c["Add"].([]byte); ok && !allZero(add) {
+			return error.NewError(fmt.Sprint("section ", sy, " has block ids over 255"), nil)
+		}
+		if sy < 0 || sy >= anvilMaxSection {
+			return error.NewError(fmt.Sprint("section ", sy, " is out of range"), nil)
+		}
+		if sy >= alphaSections {
+			if !allZero(blocks) {
+				return error.NewError(fmt.Sprintf("blocks above y=%d don't fit in an Alpha chunk", ChunkSizeY-1), nil)
+			}
+			continue
+		}
+		present[sy] = true
+		for x := 0; x < ChunkSizeX; x++ {
+			for z := 0; z < ChunkSizeZ; z++ {
+				for y := 0; y < sectionHeight; y++ {
+					i, j := blockIndex(x, sy*sectionHeight+y, z), sectionIndex(x, y, z)
+					l.Blocks[i] = blocks[j]
+					setNibble(l.Data, i, getNibble(data, j))
+					setNibble(l.SkyLight, i, getNibble(sky, j))
+					setNibble(l.BlockLight, i, getNibble(light, j))
+				}
+			}
+		}
+	}
+	// nothing is above y=127, so Anvil's heights fit in a byte.
+	if heights, ok := level["HeightMap"].([]int32); ok && len(heights) == len(l.HeightMap) {
+		for i, h := range heights {
+			l.HeightMap[i] = byte(h)
+		}
+	} else {
+		l.computeHeightMap()
+	}
+	for sy, ok := range present {
+		if ok {
+			continue
+		}
+		for x := 0; x < ChunkSizeX; x++ {
+			for z := 0; z < ChunkSizeZ; z++ {
+				h := l.Height(x, z)
+				for y := sy * sectionHeight; y < (sy+1)*sectionHeight; y++ {
+					if y >= h {
+						l.SetSkyLight(x, y, z, maxLight)
+					}
+				}
+			}
+		}
+	}
+	for _, name := range []string{"Sections", "Biomes"} {
+		level[name] = nil, false
+	}
+	level["Blocks"] = l.Blocks
+	level["Data"] = l.Data
+	level["SkyLight"] = l.SkyLight
+	level["BlockLight"] = l.BlockLight
+	level["HeightMap"] = l.HeightMap
+	return
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package world
+
+import "minecraft/registry"
+
+import "bytes"
+import "io/ioutil"
+import "os"
+import "path"
+import "testing"
+
+func TestConvert(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 5, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	// the very top of an Alpha chunk, and a block with data.
+	if err := w.SetBlock(0, 127, 0, registry.Glass, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetBlock(1, 120, 1, registry.Wool, 5); err != nil {
+		t.Fatal(err)
+	}
+	chunk := w.Chunk(0, 0)
+	chunk.Level.Entities = append(chunk.Level.Entities, &Pig{Saddle: 1})
+	chunk.dirty = true
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	anvil, mcregion, alpha := path.Join(dir, "anvil"), path.Join(dir, "mcregion"), path.Join(dir, "alpha")
+	for _, step := range []struct {
+		from, to string
+		format   Format
+	}{{dir, anvil, Anvil}, {anvil, mcregion, McRegion}, {mcregion, alpha, Alpha}} {
+		report, err := Convert(step.from, step.to, step.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Chunks != 5 {
+			t.Errorf("expected 5 chunks converted to %s, got %d", step.format, report.Chunks)
+		}
+		if f, err := DetectFormat(step.to); err != nil || f != step.format {
+			t.Errorf("expected a %s world, got %s (%v)", step.format, f, err)
+		}
+	}
+
+	// (0, 0), (1, 0) and (0, 1) share a region; (-1, 0) and (0, -1) have one each.
+	r, err := openRegion(regionPath(anvil, 0, 0, "mca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := r.read(0, 0)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := payload["Level"].(map[string]interface{})
+	if _, ok := level["Blocks"]; ok {
+		t.Error("expected an Anvil chunk not to have Blocks")
+	}
+	if _, ok := level["HeightMap"].([]int32); !ok {
+		t.Errorf("expected an Anvil height map to be ints, got %T", level["HeightMap"])
+	}
+	if n := countChunks(mcregion, McRegion); n != 5 {
+		t.Error("expected 5 chunks in the McRegion world, got ", n)
+	}
+	if _, err = Open(anvil); err == nil {
+		t.Error("expected opening an Anvil world to fail")
+	}
+
+	// after going all the way round, the world should be as it was.
+	before, err := OpenReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer before.Close()
+	after, err := OpenReadOnly(alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer after.Close()
+	for _, xz := range before.ChunkCoords() {
+		a, err := before.LoadChunkAsync(xz.X(), xz.Z()).Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := after.LoadChunkAsync(xz.X(), xz.Z()).Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Level.Blocks, b.Level.Blocks) || !bytes.Equal(a.Level.Data, b.Level.Data) || !bytes.Equal(a.Level.HeightMap, b.Level.HeightMap) {
+			t.Errorf("chunk (%d, %d) changed on the way round", xz.X(), xz.Z())
+		}
+		if len(a.Level.Entities) != len(b.Level.Entities) {
+			t.Errorf("chunk (%d, %d) had %d entities, now %d", xz.X(), xz.Z(), len(a.Level.Entities), len(b.Level.Entities))
+		}
+	}
+	if pig, ok := after.Chunk(0, 0).Level.Entities[0].(*Pig); !ok || pig.Saddle != 1 {
+		t.Error("expected the saddled pig to survive, got ", after.Chunk(0, 0).Level.Entities)
+	}
+}
+
+func TestConvertTooTall(t *testing.T) {
+	level := map[string]interface{}{
+		"Sections": []interface{}{map[string]interface{}{
+			"Y":          int8(8),
+			"Blocks":     append(make([]byte, sectionBlocks-1), registry.Stone),
+			"Data":       make([]byte, sectionBlocks/2),
+			"SkyLight":   make([]byte, sectionBlocks/2),
+			"BlockLight": make([]byte, sectionBlocks/2),
+		}},
+	}
+	if err := fromSections(level); err == nil {
+		t.Error("expected a block at y=143 not to fit in an Alpha chunk")
+	}
+}
+
+func TestFromSectionsSkyLight(t *testing.T) {
+	blocks := make([]byte, sectionBlocks)
+	for i := range blocks {
+		blocks[i] = registry.Stone
+	}
+	// only y=32 to 47 is saved: solid stone, with nothing above or below it.
+	level := map[string]interface{}{
+		"Sections": []interface{}{map[string]interface{}{
+			"Y":          int8(2),
+			"Blocks":     blocks,
+			"Data":       make([]byte, sectionBlocks/2),
+			"SkyLight":   make([]byte, sectionBlocks/2),
+			"BlockLight": make([]byte, sectionBlocks/2),
+		}},
+	}
+	if err := fromSections(level); err != nil {
+		t.Fatal(err)
+	}
+	l := &Level{SkyLight: level["SkyLight"].([]byte)}
+	if light := l.SkyLightAt(3, 10, 3); light != 0 {
+		t.Error("expected no sky light under the stone, got ", light)
+	}
+	if light := l.SkyLightAt(3, 60, 3); light != maxLight {
+		t.Error("expected full sky light above the stone, got ", light)
+	}
+}
+
+func TestRegionWriterAbandon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "r.0.0.mca")
+	w, err := createRegion(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.write(0, 0, map[string]interface{}{"Level": map[string]interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	w.abandon()
+	if exists(file) || exists(file+".tmp") {
+		t.Error("expected an abandoned region to leave nothing behind")
+	}
+}
@@ -0,0 +1,125 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+import "path"
+import "time"
+
+type CreateOptions struct {
+	// 0 picks a seed from the clock.
+	Seed int64
+	// Where new players appear.  The height is worked out from the terrain.
+	SpawnX, SpawnZ int32
+	// Time of day, in ticks.
+	Time int64
+	// Used for every chunk of the new world.  nil means the default generator.
+	Generator Generator
+	// If set, the chunks within PregenerateRadius chunks of spawn are generated and
+	// saved before Create returns.
+	Pregenerate       bool
+	PregenerateRadius int32
+}
+
+func nowMsec() int64 {
+	return time.Nanoseconds() / 1e6
+}
+
+// Creates a brand new world in dir, which must not already hold one, and opens it.
+// options may be nil for a random seed and the default generator.
+func Create(dir string, options *CreateOptions) (w *World, err os.Error) {
+	var opts CreateOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Nanoseconds()
+	}
+	if opts.Generator == nil {
+		opts.Generator = NewDefaultGenerator(opts.Seed)
+	}
+	if exists(path.Join(dir, leveldat)) {
+		err = error.NewError(fmt.Sprint("there is already a world in ", dir), nil)
+		return
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = error.NewError("could not create world directory", err)
+		return
+	}
+	if err = writeSessionLock(dir); err != nil {
+		return
+	}
+
+	spawnY, err := spawnHeight(opts.Generator, opts.SpawnX, opts.SpawnZ)
+	if err != nil {
+		err = error.NewError("could not find the height of spawn", err)
+		return
+	}
+	data := Data{
+		Time:       opts.Time,
+		SpawnX:     opts.SpawnX,
+		SpawnY:     spawnY,
+		SpawnZ:     opts.SpawnZ,
+		LastPlayed: nowMsec(),
+		RandomSeed: opts.Seed,
+	}
+	level := map[string]interface{}{"Data": data.toNBT()}
+	if err = nbt.Save(path.Join(dir, leveldat), "", level); err != nil {
+		err = error.NewError("could not write level", err)
+		return
+	}
+
+	if w, err = Open(dir); err != nil {
+		return
+	}
+	w.Generator = opts.Generator
+	if opts.Pregenerate {
+		if err = w.pregenerate(opts.SpawnX>>4, opts.SpawnZ>>4, opts.PregenerateRadius); err != nil {
+			w.Close()
+			w = nil
+			return
+		}
+	}
+	return
+}
+
+// Open insists on a session.lock being there already; it overwrites it straight away.
+func writeSessionLock(dir string) (err os.Error) {
+	f, err := os.Open(path.Join(dir, sessionlock), os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not create ", sessionlock), err)
+		return
+	}
+	defer f.Close()
+	if err = nbt.WriteInt64(f, nowMsec()); err != nil {
+		err = error.NewError(fmt.Sprint("could not write ", sessionlock), err)
+		return
+	}
+	return
+}
+
+// Spawn goes on top of whatever the generator puts at (x, z).
+func spawnHeight(g Generator, x int32, z int32) (y int32, err os.Error) {
+	chunk, err := g.Generate(x>>4, z>>4)
+	if err != nil {
+		return
+	}
+	y = int32(chunk.Level.Height(int(x&15), int(z&15)))
+	return
+}
+
+func (world *World) pregenerate(x int32, z int32, radius int32) (err os.Error) {
+	for _, f := range world.Prefetch(x, z, radius) {
+		if _, err = f.Wait(); err != nil {
+			err = error.NewError(fmt.Sprintf("could not generate chunk (%d, %d)", f.X, f.Z), err)
+			return
+		}
+	}
+	if err = world.Flush(); err != nil {
+		err = error.NewError("could not save generated chunks", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,468 @@
+package world
+
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// Entities are everything in a chunk that moves: mobs, dropped items, arrows and vehicles.
+// see: http://www.minecraftwiki.net/wiki/Alpha_Level_Format/Chunk_File_Format#Entity_Format
+type Entity interface {
+	Id() string
+	Base() *EntityBase
+	// Returns the entity as it would be saved in a chunk.
+	ToNBT() map[string]interface{}
+}
+
+// Fields every entity has.
+type EntityBase struct {
+	Physics      Physics
+	FallDistance float32
+	Fire         int16
+	Air          int16
+	OnGround     int8
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
+}
+
+func (b *EntityBase) Base() *EntityBase {
+	return b
+}
+
+func (b *EntityBase) read(r *compoundReader) {
+	b.Physics = toPhysics(r)
+	b.FallDistance = r.float32("FallDistance")
+	b.Fire = r.int16("Fire")
+	b.Air = r.int16("Air")
+	b.OnGround = r.int8("OnGround")
+}
+
+func (b *EntityBase) toNBT(id string) map[string]interface{} {
+	c := newCompound(b.Extra)
+	c["id"] = id
+	b.Physics.toNBT(c)
+	c["FallDistance"] = b.FallDistance
+	c["Fire"] = b.Fire
+	c["Air"] = b.Air
+	c["OnGround"] = b.OnGround
+	return c
+}
+
+// A dropped item.
+type ItemEntity struct {
+	EntityBase
+	Health int16
+	Age    int16
+	Item   Item
+}
+
+func (e *ItemEntity) Id() string {
+	return "Item"
+}
+
+func (e *ItemEntity) ToNBT() map[string]interface{} {
+	c := e.toNBT(e.Id())
+	c["Health"] = e.Health
+	c["Age"] = e.Age
+	item := make(map[string]interface{})
+	e.Item.toNBT(item)
+	c["Item"] = item
+	return c
+}
+
+// Arrows and snowballs remember the block they are stuck in.
+type Projectile struct {
+	EntityBase
+	XTile, YTile, ZTile int16
+	InTile              int8
+	Shake               int8
+	InGround            int8
+}
+
+func (p *Projectile) read(r *compoundReader) {
+	p.XTile = r.int16("xTile")
+	p.YTile = r.int16("yTile")
+	p.ZTile = r.int16("zTile")
+	p.InTile = r.int8("inTile")
+	p.Shake = r.int8("shake")
+	p.InGround = r.int8("inGround")
+}
+
+func (p *Projectile) toNBT(id string) map[string]interface{} {
+	c := p.EntityBase.toNBT(id)
+	c["xTile"] = p.XTile
+	c["yTile"] = p.YTile
+	c["zTile"] = p.ZTile
+	c["inTile"] = p.InTile
+	c["shake"] = p.Shake
+	c["inGround"] = p.InGround
+	return c
+}
+
+type Arrow struct {
+	Projectile
+}
+
+func (a *Arrow) Id() string {
+	return "Arrow"
+}
+
+func (a *Arrow) ToNBT() map[string]interface{} {
+	return a.toNBT(a.Id())
+}
+
+type Snowball struct {
+	Projectile
+}
+
+func (s *Snowball) Id() string {
+	return "Snowball"
+}
+
+func (s *Snowball) ToNBT() map[string]interface{} {
+	return s.toNBT(s.Id())
+}
+
+type Painting struct {
+	EntityBase
+	Dir                 int8
+	Motive              string
+	TileX, TileY, TileZ int32
+}
+
+func (p *Painting) Id() string {
+	return "Painting"
+}
+
+func (p *Painting) ToNBT() map[string]interface{} {
+	c := p.toNBT(p.Id())
+	c["Dir"] = p.Dir
+	c["Motive"] = p.Motive
+	c["TileX"] = p.TileX
+	c["TileY"] = p.TileY
+	c["TileZ"] = p.TileZ
+	return c
+}
+
+type PrimedTnt struct {
+	EntityBase
+	Fuse int8
+}
+
+func (t *PrimedTnt) Id() string {
+	return "PrimedTnt"
+}
+
+func (t *PrimedTnt) ToNBT() map[string]interface{} {
+	c := t.toNBT(t.Id())
+	c["Fuse"] = t.Fuse
+	return c
+}
+
+// Sand or gravel on its way down.
+type FallingSand struct {
+	EntityBase
+	Tile int8
+}
+
+func (f *FallingSand) Id() string {
+	return "FallingSand"
+}
+
+func (f *FallingSand) ToNBT() map[string]interface{} {
+	c := f.toNBT(f.Id())
+	c["Tile"] = f.Tile
+	return c
+}
+
+const (
+	MinecartRideable = 0
+	MinecartChest    = 1
+	MinecartFurnace  = 2
+)
+
+// Chest minecarts carry Items, furnace minecarts PushX, PushZ and Fuel.  The
+// fields that don't apply to a minecart's Type aren't saved.
+type Minecart struct {
+	EntityBase
+	Type         int32
+	Items        []InventorySlot
+	PushX, PushZ float64
+	Fuel         int16
+}
+
+func (m *Minecart) Id() string {
+	return "Minecart"
+}
+
+func (m *Minecart) ToNBT() map[string]interface{} {
+	c := m.toNBT(m.Id())
+	c["Type"] = m.Type
+	switch m.Type {
+	case MinecartChest:
+		c["Items"] = inventoryToNBT(m.Items)
+	case MinecartFurnace:
+		c["PushX"] = m.PushX
+		c["PushZ"] = m.PushZ
+		c["Fuel"] = m.Fuel
+	}
+	return c
+}
+
+type Boat struct {
+	EntityBase
+}
+
+func (b *Boat) Id() string {
+	return "Boat"
+}
+
+func (b *Boat) ToNBT() map[string]interface{} {
+	return b.toNBT(b.Id())
+}
+
+// Fields every living thing has.
+type Mob struct {
+	EntityBase
+	AttackTime int16
+	DeathTime  int16
+	HurtTime   int16
+	Health     int16
+}
+
+func (m *Mob) read(r *compoundReader) {
+	m.AttackTime = r.int16("AttackTime")
+	m.DeathTime = r.int16("DeathTime")
+	m.HurtTime = r.int16("HurtTime")
+	m.Health = r.int16("Health")
+}
+
+func (m *Mob) toNBT(id string) map[string]interface{} {
+	c := m.EntityBase.toNBT(id)
+	c["AttackTime"] = m.AttackTime
+	c["DeathTime"] = m.DeathTime
+	c["HurtTime"] = m.HurtTime
+	c["Health"] = m.Health
+	return c
+}
+
+// A mob with nothing beyond the common mob fields.  Most of them are like this, so
+// they share a type; Id tells them apart.
+type SimpleMob struct {
+	Mob
+	id string
+}
+
+func (m *SimpleMob) Id() string {
+	return m.id
+}
+
+func (m *SimpleMob) ToNBT() map[string]interface{} {
+	return m.toNBT(m.id)
+}
+
+// The mobs that are SimpleMobs.
+var simpleMobs = []string{
+	"Mob", "Monster", "Creeper", "Skeleton", "Spider", "Giant", "Zombie", "Ghast", "Cow", "Chicken",
+}
+
+type Pig struct {
+	Mob
+	Saddle int8
+}
+
+func (p *Pig) Id() string {
+	return "Pig"
+}
+
+func (p *Pig) ToNBT() map[string]interface{} {
+	c := p.toNBT(p.Id())
+	c["Saddle"] = p.Saddle
+	return c
+}
+
+type Sheep struct {
+	Mob
+	Sheared int8
+}
+
+func (s *Sheep) Id() string {
+	return "Sheep"
+}
+
+func (s *Sheep) ToNBT() map[string]interface{} {
+	c := s.toNBT(s.Id())
+	c["Sheared"] = s.Sheared
+	return c
+}
+
+type Slime struct {
+	Mob
+	Size int32
+}
+
+func (s *Slime) Id() string {
+	return "Slime"
+}
+
+func (s *Slime) ToNBT() map[string]interface{} {
+	c := s.toNBT(s.Id())
+	c["Size"] = s.Size
+	return c
+}
+
+type PigZombie struct {
+	Mob
+	Anger int16
+}
+
+func (p *PigZombie) Id() string {
+	return "PigZombie"
+}
+
+func (p *PigZombie) ToNBT() map[string]interface{} {
+	c := p.toNBT(p.Id())
+	c["Anger"] = p.Anger
+	return c
+}
+
+// An entity whose id isn't registered.  Only the base fields are understood, and
+// they are written back as zeros if they were missing; the rest is kept in Extra.
+type UnknownEntity struct {
+	EntityBase
+	id string
+}
+
+func (u *UnknownEntity) Id() string {
+	return u.id
+}
+
+func (u *UnknownEntity) ToNBT() map[string]interface{} {
+	return u.toNBT(u.id)
+}
+
+// Reads the fields particular to one kind of entity.  The base fields are read
+// afterwards.
+type entityReader func(r *compoundReader) Entity
+
+func readMob(r *compoundReader) (m Mob) {
+	m.read(r)
+	return
+}
+
+func readProjectile(r *compoundReader) (p Projectile) {
+	p.read(r)
+	return
+}
+
+var entityReaders = map[string]entityReader{
+	"Item": func(r *compoundReader) Entity {
+		e := &ItemEntity{
+			Health: r.int16("Health"),
+			Age:    r.int16("Age"),
+		}
+		ir := newCompoundReader(r.compound("Item"))
+		e.Item = toItem(ir)
+		if ir.err != nil && r.err == nil {
+			r.err = ir.err
+		}
+		return e
+	},
+	"Arrow": func(r *compoundReader) Entity {
+		return &Arrow{readProjectile(r)}
+	},
+	"Snowball": func(r *compoundReader) Entity {
+		return &Snowball{readProjectile(r)}
+	},
+	"Painting": func(r *compoundReader) Entity {
+		return &Painting{
+			Dir:    r.int8("Dir"),
+			Motive: r.string("Motive"),
+			TileX:  r.int32("TileX"),
+			TileY:  r.int32("TileY"),
+			TileZ:  r.int32("TileZ"),
+		}
+	},
+	"PrimedTnt": func(r *compoundReader) Entity {
+		return &PrimedTnt{Fuse: r.int8("Fuse")}
+	},
+	"FallingSand": func(r *compoundReader) Entity {
+		return &FallingSand{Tile: r.int8("Tile")}
+	},
+	"Minecart": func(r *compoundReader) Entity {
+		m := &Minecart{Type: r.int32("Type")}
+		switch m.Type {
+		case MinecartChest:
+			m.Items = toInventory(r, "Items")
+		case MinecartFurnace:
+			m.PushX = r.float64("PushX")
+			m.PushZ = r.float64("PushZ")
+			m.Fuel = r.int16("Fuel")
+		}
+		return m
+	},
+	"Boat": func(r *compoundReader) Entity {
+		return &Boat{}
+	},
+	"Pig": func(r *compoundReader) Entity {
+		return &Pig{readMob(r), r.int8("Saddle")}
+	},
+	"Sheep": func(r *compoundReader) Entity {
+		return &Sheep{readMob(r), r.int8("Sheared")}
+	},
+	"Slime": func(r *compoundReader) Entity {
+		return &Slime{readMob(r), r.int32("Size")}
+	},
+	"PigZombie": func(r *compoundReader) Entity {
+		return &PigZombie{readMob(r), r.int16("Anger")}
+	},
+}
+
+func init() {
+	for _, id := range simpleMobs {
+		mobid := id
+		entityReaders[id] = func(r *compoundReader) Entity {
+			return &SimpleMob{readMob(r), mobid}
+		}
+	}
+}
+
+func toEntity(payload map[string]interface{}) (e Entity, err os.Error) {
+	r := newCompoundReader(payload)
+	id := r.string("id")
+	if read, ok := entityReaders[id]; ok {
+		e = read(r)
+	} else {
+		e = &UnknownEntity{id: id}
+	}
+	base := e.Base()
+	base.read(r)
+	base.Extra = r.extra()
+	if r.err != nil {
+		err = error.NewError(fmt.Sprint("could not understand entity ", id), r.err)
+	}
+	return
+}
+
+func toEntityList(payload []interface{}) (entities []Entity, err os.Error) {
+	entities = make([]Entity, len(payload))
+	for i, v := range payload {
+		c, ok := v.(map[string]interface{})
+		if !ok {
+			err = error.NewError(fmt.Sprintf("entity %d is a %T, not a compound", i, v), nil)
+			return
+		}
+		if entities[i], err = toEntity(c); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func entityListToNBT(entities []Entity) []interface{} {
+	l := make([]interface{}, len(entities))
+	for i, e := range entities {
+		l[i] = e.ToNBT()
+	}
+	return l
+}
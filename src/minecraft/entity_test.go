@@ -0,0 +1,74 @@
+package world
+
+import "reflect"
+import "testing"
+
+func entityPayload(id string, fields map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"id":           id,
+		"Pos":          []interface{}{float64(1.5), float64(70), float64(-2.5)},
+		"Motion":       []interface{}{float64(0), float64(-0.1), float64(0)},
+		"Rotation":     []interface{}{float32(45), float32(-10)},
+		"FallDistance": float32(0),
+		"Fire":         int16(-1),
+		"Air":          int16(300),
+		"OnGround":     int8(1),
+	}
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+func mobPayload(id string, fields map[string]interface{}) map[string]interface{} {
+	c := entityPayload(id, fields)
+	c["AttackTime"] = int16(0)
+	c["DeathTime"] = int16(0)
+	c["HurtTime"] = int16(0)
+	c["Health"] = int16(10)
+	return c
+}
+
+func TestEntityRoundTrip(t *testing.T) {
+	payloads := []interface{}{
+		entityPayload("Item", map[string]interface{}{
+			"Health": int16(5), "Age": int16(100),
+			"Item": map[string]interface{}{"id": int16(4), "Count": int8(1), "Damage": int16(0)},
+		}),
+		entityPayload("Arrow", map[string]interface{}{
+			"xTile": int16(1), "yTile": int16(64), "zTile": int16(2),
+			"inTile": int8(1), "shake": int8(0), "inGround": int8(1),
+		}),
+		entityPayload("Minecart", map[string]interface{}{"Type": int32(MinecartFurnace), "PushX": float64(1), "PushZ": float64(0), "Fuel": int16(3600)}),
+		entityPayload("Boat", nil),
+		mobPayload("Creeper", nil),
+		mobPayload("Pig", map[string]interface{}{"Saddle": int8(1)}),
+		entityPayload("Wolf", map[string]interface{}{"Owner": "Notch"}),
+	}
+	entities, err := toEntityList(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, ok := entities[0].(*ItemEntity); !ok || item.Item.Id != 4 {
+		t.Error("expected a cobblestone item, got ", entities[0])
+	}
+	if creeper, ok := entities[4].(*SimpleMob); !ok || creeper.Id() != "Creeper" || creeper.Health != 10 {
+		t.Error("expected a creeper, got ", entities[4])
+	}
+	if pig, ok := entities[5].(*Pig); !ok || pig.Saddle != 1 || pig.Base().Physics.Euler.Yaw != 45 {
+		t.Error("expected a saddled pig facing 45 degrees, got ", entities[5])
+	}
+	if saved := entityListToNBT(entities); !reflect.DeepEqual(saved, payloads) {
+		t.Error("expected ", payloads, ", got ", saved)
+	}
+}
+
+func TestEntityOddShape(t *testing.T) {
+	_, err := toEntity(map[string]interface{}{
+		"id":  "Pig",
+		"Pos": []interface{}{float64(1), float64(2)},
+	})
+	if err == nil {
+		t.Error("expected an error for a Pos with two coordinates")
+	}
+}
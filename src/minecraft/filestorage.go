@@ -0,0 +1,418 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "bytes"
+import "fmt"
+import "hash/crc32"
+import "os"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "syscall"
+
+// What a FileStorage keeps things under.
+const (
+	levelKey        = "level"
+	lockKey         = "lock"
+	chunkKeyPrefix  = "chunk."
+	playerKeyPrefix = "player."
+)
+
+// Every record starts with a checksum of the rest of it, then the lengths of its key
+// and value.  A value length of -1 marks a removed key.
+const (
+	recordHeader = 4 + 2 + 4
+	removed      = -1
+)
+
+// Keeps a whole world in one file, so a big world isn't millions of little files.
+// The file is a log: every put appends a record with the new value, and where each
+// key's latest value is gets worked out again when the file is opened.  A record
+// cut short by a crash fails its checksum, and it and anything after it are
+// dropped.  Replaced values go on taking up space until Compact.  Several processes
+// may have the file open at once; each holds an flock on it while reading new
+// records or appending, so nobody reads or truncates a record still being written.
+type FileStorage struct {
+	lock  sync.Mutex
+	f     *os.File
+	file  string
+	index map[string]span
+	// where the next record goes.
+	end int64
+	// bytes of records whose values have since been replaced or removed.
+	garbage int64
+}
+
+// Where a key's value is in the file.
+type span struct {
+	offset int64
+	length int32
+	// of the whole record.
+	size int64
+}
+
+// Opens file, creating it if it doesn't exist.  Close it when done; OpenStorage
+// doesn't take ownership.
+func OpenFileStorage(file string) (s *FileStorage, err os.Error) {
+	f, err := os.Open(file, os.O_RDWR|os.O_CREAT, 0644)
+	if err != nil {
+		err = error.NewError("could not open storage file", err)
+		return
+	}
+	s = &FileStorage{f: f, file: file, index: make(map[string]span)}
+	if err = flock(f, syscall.LOCK_EX); err == nil {
+		if err = s.scan(); err == nil {
+			// a record cut short would be in the way of the next one.
+			err = f.Truncate(s.end)
+		}
+		flock(f, syscall.LOCK_UN)
+	}
+	if err != nil {
+		f.Close()
+		s = nil
+		err = error.NewError("could not read storage file", err)
+		return
+	}
+	return
+}
+
+func (s *FileStorage) Close() os.Error {
+	return s.f.Close()
+}
+
+// Takes or drops an advisory lock on the whole file, shared between processes.
+func flock(f *os.File, how int) os.Error {
+	if errno := syscall.Flock(f.Fd(), how); errno != 0 {
+		return os.NewSyscallError("flock", errno)
+	}
+	return nil
+}
+
+// Catches up with whatever other processes have appended.  Callers hold s.lock.
+func (s *FileStorage) catchUp() (err os.Error) {
+	if err = flock(s.f, syscall.LOCK_SH); err != nil {
+		return
+	}
+	defer flock(s.f, syscall.LOCK_UN)
+	return s.scan()
+}
+
+// Catches up if the file has grown since the last scan, so a process that only
+// reads still sees what the others write.  Callers hold s.lock.
+func (s *FileStorage) refresh() (err os.Error) {
+	fi, err := s.f.Stat()
+	if err != nil || fi.Size <= s.end {
+		return
+	}
+	return s.catchUp()
+}
+
+// Reads records from s.end on, as far as they are whole.  Callers hold s.lock and
+// an flock on the file.
+func (s *FileStorage) scan() (err os.Error) {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return
+	}
+	for s.end+recordHeader <= fi.Size {
+		header := make([]byte, recordHeader)
+		if _, err = s.f.ReadAt(header, s.end); err != nil {
+			return
+		}
+		buf := bytes.NewBuffer(header)
+		sum, _ := nbt.ReadInt32(buf)
+		keyLength, _ := nbt.ReadInt16(buf)
+		valueLength, _ := nbt.ReadInt32(buf)
+		if keyLength < 0 || valueLength < removed {
+			break
+		}
+		n := int64(keyLength)
+		if valueLength > 0 {
+			n += int64(valueLength)
+		}
+		if s.end+recordHeader+n > fi.Size {
+			break
+		}
+		body := make([]byte, n)
+		if _, err = s.f.ReadAt(body, s.end+recordHeader); err != nil {
+			return
+		}
+		if uint32(sum) != crc32.ChecksumIEEE(append(header[4:], body...)) {
+			break
+		}
+		s.index1(string(body[:keyLength]), valueLength, recordHeader+n)
+	}
+	return
+}
+
+// Notes the record at s.end in the index, and moves s.end past it.
+func (s *FileStorage) index1(key string, valueLength int32, size int64) {
+	if old, ok := s.index[key]; ok {
+		s.garbage += old.size
+	}
+	if valueLength == removed {
+		s.index[key] = span{}, false
+		s.garbage += size
+	} else {
+		s.index[key] = span{s.end + recordHeader + int64(len(key)), valueLength, size}
+	}
+	s.end += size
+}
+
+func (s *FileStorage) get(key string) (value []byte, err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = s.refresh(); err != nil {
+		return
+	}
+	return s.read(key)
+}
+
+// Like get, for callers that already hold s.lock.
+func (s *FileStorage) read(key string) (value []byte, err os.Error) {
+	sp, ok := s.index[key]
+	if !ok {
+		return
+	}
+	value = make([]byte, sp.length)
+	if _, err = s.f.ReadAt(value, sp.offset); err != nil {
+		value = nil
+	}
+	return
+}
+
+// Appends a record setting key to value, or removing it if remove is set.
+func (s *FileStorage) put(key string, value []byte, remove bool) (err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = flock(s.f, syscall.LOCK_EX); err != nil {
+		return
+	}
+	defer flock(s.f, syscall.LOCK_UN)
+	// if another process has appended since, don't write over it.
+	if err = s.scan(); err != nil {
+		return
+	}
+	return s.append(key, value, remove)
+}
+
+// Writes a record at s.end.  Callers hold s.lock and an exclusive flock, and have
+// just scanned.
+func (s *FileStorage) append(key string, value []byte, remove bool) (err os.Error) {
+	if _, ok := s.index[key]; !ok && remove {
+		return
+	}
+	valueLength := int32(len(value))
+	if remove {
+		valueLength, value = removed, nil
+	}
+	var rec bytes.Buffer
+	nbt.WriteInt16(&rec, int16(len(key)))
+	nbt.WriteInt32(&rec, valueLength)
+	rec.WriteString(key)
+	rec.Write(value)
+	var out bytes.Buffer
+	nbt.WriteInt32(&out, int32(crc32.ChecksumIEEE(rec.Bytes())))
+	out.Write(rec.Bytes())
+	if _, err = s.f.WriteAt(out.Bytes(), s.end); err != nil {
+		return
+	}
+	s.index1(key, valueLength, int64(out.Len()))
+	return
+}
+
+// How many bytes Compact would free.
+func (s *FileStorage) Garbage() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.garbage
+}
+
+// Rewrites the file with just the latest value of every key.  Nobody else may have
+// the file open.
+func (s *FileStorage) Compact() (err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tmp := s.file + ".tmp"
+	f, err := os.Open(tmp, os.O_RDWR|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		return error.NewError("could not create storage file", err)
+	}
+	c := &FileStorage{f: f, file: s.file, index: make(map[string]span)}
+	var keys []string
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	sort.SortStrings(keys)
+	for _, key := range keys {
+		sp := s.index[key]
+		value := make([]byte, sp.length)
+		if _, err = s.f.ReadAt(value, sp.offset); err == nil {
+			err = c.put(key, value, false)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return error.NewError("could not copy "+key, err)
+		}
+	}
+	if err = os.Rename(tmp, s.file); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return error.NewError("could not replace storage file", err)
+	}
+	s.f.Close()
+	s.f, s.index, s.end, s.garbage = c.f, c.index, c.end, c.garbage
+	return
+}
+
+func (s *FileStorage) Level() (level []byte, err os.Error) {
+	if level, err = s.get(levelKey); err == nil && level == nil {
+		err = error.NewError(fmt.Sprint("there is no ", leveldat), nil)
+	}
+	return
+}
+
+func (s *FileStorage) PutLevel(level []byte) os.Error {
+	return s.put(levelKey, level, false)
+}
+
+func chunkKey(x int32, z int32) string {
+	return fmt.Sprintf("%s%d.%d", chunkKeyPrefix, x, z)
+}
+
+func parseChunkKey(key string) (x int32, z int32, ok bool) {
+	if !strings.HasPrefix(key, chunkKeyPrefix) {
+		return
+	}
+	xz := strings.Split(key[len(chunkKeyPrefix):], ".", -1)
+	if len(xz) != 2 {
+		return
+	}
+	cx, e1 := strconv.Atoi(xz[0])
+	cz, e2 := strconv.Atoi(xz[1])
+	if e1 != nil || e2 != nil {
+		return
+	}
+	return int32(cx), int32(cz), true
+}
+
+func (s *FileStorage) Chunk(x int32, z int32) ([]byte, os.Error) {
+	return s.get(chunkKey(x, z))
+}
+
+func (s *FileStorage) PutChunk(x int32, z int32, chunk []byte) os.Error {
+	return s.put(chunkKey(x, z), chunk, false)
+}
+
+func (s *FileStorage) RemoveChunk(x int32, z int32) os.Error {
+	return s.put(chunkKey(x, z), nil, true)
+}
+
+func (s *FileStorage) ChunkCoords() (coords []XZ) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// the interface has no room for an error; what we already know will have to do.
+	s.refresh()
+	for key := range s.index {
+		if x, z, ok := parseChunkKey(key); ok {
+			coords = append(coords, MakeXZ(x, z))
+		}
+	}
+	return
+}
+
+func (s *FileStorage) ChunkSize(x int32, z int32) (int64, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.refresh(); err != nil {
+		return 0, err
+	}
+	sp, ok := s.index[chunkKey(x, z)]
+	if !ok {
+		return 0, error.NewError(fmt.Sprintf("there is no chunk (%d, %d)", x, z), nil)
+	}
+	return sp.size, nil
+}
+
+func (s *FileStorage) Player(name string) ([]byte, os.Error) {
+	return s.get(playerKeyPrefix + name)
+}
+
+func (s *FileStorage) PutPlayer(name string, player []byte) os.Error {
+	return s.put(playerKeyPrefix+name, player, false)
+}
+
+func (s *FileStorage) PlayerNames() (names []string, err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = s.refresh(); err != nil {
+		return
+	}
+	for key := range s.index {
+		if strings.HasPrefix(key, playerKeyPrefix) {
+			names = append(names, key[len(playerKeyPrefix):])
+		}
+	}
+	return
+}
+
+// Works like MemoryStorage's lock: locking appends the next generation, and
+// whoever appended the latest one has the lock.  The flock around reading the last
+// generation and appending the next means no two locks get the same one, even
+// from different processes.
+type fileLock struct {
+	s          *FileStorage
+	generation int64
+}
+
+func (s *FileStorage) Lock() (l SessionLock, err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = flock(s.f, syscall.LOCK_EX); err != nil {
+		return
+	}
+	defer flock(s.f, syscall.LOCK_UN)
+	if err = s.scan(); err != nil {
+		return
+	}
+	generation, err := s.generation()
+	if err != nil {
+		return
+	}
+	generation++
+	var b bytes.Buffer
+	nbt.WriteInt64(&b, generation)
+	if err = s.append(lockKey, b.Bytes(), false); err != nil {
+		return
+	}
+	return &fileLock{s, generation}, nil
+}
+
+// The generation of the latest lock, or 0 if the file has never been locked.
+// Callers hold s.lock.
+func (s *FileStorage) generation() (generation int64, err os.Error) {
+	b, err := s.read(lockKey)
+	if err != nil || b == nil {
+		return
+	}
+	return nbt.ReadInt64(bytes.NewBuffer(b))
+}
+
+func (l *fileLock) Held() (held bool, err os.Error) {
+	l.s.lock.Lock()
+	defer l.s.lock.Unlock()
+	// another process opening the file appends to it; catch up with what it wrote.
+	if err = l.s.catchUp(); err != nil {
+		return
+	}
+	generation, err := l.s.generation()
+	return err == nil && generation == l.generation, err
+}
+
+func (l *fileLock) Release() os.Error {
+	return nil
+}
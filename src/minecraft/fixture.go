@@ -0,0 +1,181 @@
+package world
+
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// Builds small worlds in memory, so tests needn't depend on a world lying about on
+// somebody's disk.  Each method adds to the world and returns the Fixture, so they
+// chain:
+//
+//	w, err := NewFixture(1).Flat(0, 0, 2, registry.Bedrock, registry.Stone).Open()
+//
+// The first thing to go wrong is remembered, and returned by Storage or Open.
+type Fixture struct {
+	data    Data
+	chunks  map[XZ]*Chunk
+	players map[string]*Player
+	// chunks whose blocks were changed after they were added, and need relighting.
+	touched map[XZ]bool
+	err     os.Error
+}
+
+// Starts an empty world with the given seed, spawning at (0, 64, 0).
+func NewFixture(seed int64) *Fixture {
+	return &Fixture{
+		data:    Data{SpawnY: seaLevel, LastPlayed: nowMsec(), RandomSeed: seed},
+		chunks:  make(map[XZ]*Chunk),
+		players: make(map[string]*Player),
+		touched: make(map[XZ]bool),
+	}
+}
+
+func (f *Fixture) Spawn(x int32, y int32, z int32) *Fixture {
+	f.data.SpawnX, f.data.SpawnY, f.data.SpawnZ = x, y, z
+	return f
+}
+
+// Sets the time of day, in ticks.
+func (f *Fixture) Time(ticks int64) *Fixture {
+	f.data.Time = ticks
+	return f
+}
+
+// Calls add for every chunk within radius chunks of (x, z), the same circle
+// Prefetch loads.
+func (f *Fixture) eachChunk(x int32, z int32, radius int32, add func(x int32, z int32) (*Chunk, os.Error)) *Fixture {
+	for dx := -radius; dx <= radius; dx++ {
+		for dz := -radius; dz <= radius; dz++ {
+			if f.err != nil || dx*dx+dz*dz > radius*radius {
+				continue
+			}
+			chunk, err := add(x+dx, z+dz)
+			if err != nil {
+				f.err = error.NewError(fmt.Sprintf("could not make chunk (%d, %d)", x+dx, z+dz), err)
+				continue
+			}
+			f.Chunk(chunk)
+		}
+	}
+	return f
+}
+
+// Adds the chunks within radius chunks of (x, z) as the default generator makes
+// them for the fixture's seed.
+func (f *Fixture) Generate(x int32, z int32, radius int32) *Fixture {
+	g := NewDefaultGenerator(f.data.RandomSeed)
+	return f.eachChunk(x, z, radius, func(x int32, z int32) (*Chunk, os.Error) {
+		return g.Generate(x, z)
+	})
+}
+
+// Adds flat chunks within radius chunks of (x, z), made of layers from y=0 up,
+// with air above.
+func (f *Fixture) Flat(x int32, z int32, radius int32, layers ...byte) *Fixture {
+	if len(layers) > ChunkSizeY {
+		f.err = error.NewError(fmt.Sprint("more layers than fit in a chunk: ", len(layers)), nil)
+		return f
+	}
+	return f.eachChunk(x, z, radius, func(x int32, z int32) (*Chunk, os.Error) {
+		chunk := &Chunk{Level: newLevel(x, z)}
+		level := &chunk.Level
+		for bx := 0; bx < ChunkSizeX; bx++ {
+			for bz := 0; bz < ChunkSizeZ; bz++ {
+				for y, id := range layers {
+					level.SetBlock(bx, y, bz, id)
+				}
+			}
+		}
+		level.computeHeightMap()
+		level.computeLight()
+		level.TerrainPopulated = 1
+		return chunk, nil
+	})
+}
+
+// Adds a chunk, replacing any already at its position.  The fixture keeps it, so it
+// mustn't be changed afterwards except through the fixture.
+func (f *Fixture) Chunk(chunk *Chunk) *Fixture {
+	if f.err == nil {
+		if f.err = chunk.Level.checkArrays(); f.err == nil {
+			f.chunks[MakeXZ(chunk.Level.XPos, chunk.Level.ZPos)] = chunk
+		}
+	}
+	return f
+}
+
+// Sets a block in a chunk that has already been added.
+func (f *Fixture) Block(x int32, y int32, z int32, id byte, data byte) *Fixture {
+	if f.err != nil {
+		return f
+	}
+	xz := MakeXZ(x>>4, z>>4)
+	chunk, ok := f.chunks[xz]
+	if !ok {
+		f.err = error.NewError(fmt.Sprintf("no chunk has been added for block (%d, %d, %d)", x, y, z), nil)
+		return f
+	}
+	if y < 0 || y >= ChunkSizeY {
+		f.err = error.NewError(fmt.Sprintf("block (%d, %d, %d) is out of the world", x, y, z), nil)
+		return f
+	}
+	chunk.Level.SetBlock(int(x&15), int(y), int(z&15), id)
+	chunk.Level.SetBlockData(int(x&15), int(y), int(z&15), data)
+	f.touched[xz] = true
+	return f
+}
+
+// Adds a multiplayer player.
+func (f *Fixture) Player(name string, p *Player) *Fixture {
+	if f.err == nil {
+		if f.err = checkPlayerName(name); f.err == nil {
+			f.players[name] = p
+		}
+	}
+	return f
+}
+
+// Writes level.dat, the chunks and the players into a new MemoryStorage.
+func (f *Fixture) Storage() (s *MemoryStorage, err os.Error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	for xz := range f.touched {
+		level := &f.chunks[xz].Level
+		level.computeHeightMap()
+		level.computeLight()
+	}
+	f.touched = make(map[XZ]bool)
+
+	s = NewMemoryStorage()
+	b, err := encodeNBT("", map[string]interface{}{"Data": f.data.toNBT()})
+	if err != nil {
+		return
+	}
+	s.PutLevel(b)
+	for xz, chunk := range f.chunks {
+		if b, err = encodeNBT("", chunk.toNBT()); err != nil {
+			return
+		}
+		s.PutChunk(xz.X(), xz.Z(), b)
+	}
+	for name, p := range f.players {
+		if b, err = encodeNBT("", p.toNBT()); err != nil {
+			return
+		}
+		s.PutPlayer(name, b)
+	}
+	return
+}
+
+// Opens the world built so far.  Like any writable world, it has the default
+// generator for its seed; set Generator to nil for chunks that weren't added to
+// fail to load instead.
+func (f *Fixture) Open() (w *World, err os.Error) {
+	s, err := f.Storage()
+	if err != nil {
+		return
+	}
+	return OpenStorage(s)
+}
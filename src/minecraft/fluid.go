@@ -0,0 +1,291 @@
+package world
+
+import "minecraft/registry"
+
+import "os"
+import "sort"
+
+// How many ticks go by between a fluid block noticing a change and moving.
+const (
+	waterTickRate = 5
+	lavaTickRate  = 30
+)
+
+// Fluid blocks keep how far they are from a source in their data: 0 for a source,
+// up to 7 for the thinnest flowing edge.  Data with this bit set is falling, and
+// spreads as if it were a source when it lands.
+const fluidFalling = 8
+
+// A block the fluid simulation changed.
+type BlockChange struct {
+	Pos      BlockPos
+	Id, Data byte
+}
+
+// Makes water and lava flow the way Alpha does.  Only fluids that have been woken
+// move: tell it about blocks that change with Wake, and call Tick once a game tick,
+// 20 times a second.  Fluids don't flow into chunks that aren't loaded.  Like
+// SetBlock, none of it is safe to call from several goroutines at once.
+type Fluids struct {
+	world *World
+	ticks int64
+	// fluid blocks waiting to move, and the tick they move on.
+	due map[BlockPos]int64
+}
+
+func NewFluids(world *World) *Fluids {
+	return &Fluids{world: world, due: make(map[BlockPos]int64)}
+}
+
+// Returns Water or Lava for any kind of water or lava, or Air for anything else.
+func fluidOf(id byte) byte {
+	switch id {
+	case registry.Water, registry.StillWater:
+		return registry.Water
+	case registry.Lava, registry.StillLava:
+		return registry.Lava
+	}
+	return registry.Air
+}
+
+func stillFluid(fluid byte) byte {
+	if fluid == registry.Water {
+		return registry.StillWater
+	}
+	return registry.StillLava
+}
+
+// How much a fluid thins with each block it spreads.
+func fluidDecay(fluid byte) int {
+	if fluid == registry.Water {
+		return 1
+	}
+	return 2
+}
+
+func fluidTickRate(fluid byte) int64 {
+	if fluid == registry.Water {
+		return waterTickRate
+	}
+	return lavaTickRate
+}
+
+// Blocks that aren't solid but still hold fluids back, rather than being washed away.
+func blocksFlow(id byte) bool {
+	switch id {
+	case registry.WoodenDoor, registry.IronDoor, registry.SignPost, registry.WallSign, registry.Ladder, registry.Reeds, registry.Portal:
+		return true
+	}
+	return registry.GetBlock(id).Solid
+}
+
+func (p BlockPos) offset(dx int32, dy int32, dz int32) BlockPos {
+	return BlockPos{p.X + dx, p.Y + dy, p.Z + dz}
+}
+
+func (p BlockPos) sides() [4]BlockPos {
+	return [4]BlockPos{p.offset(-1, 0, 0), p.offset(1, 0, 0), p.offset(0, 0, -1), p.offset(0, 0, 1)}
+}
+
+// Returns the block at p, if its chunk is loaded.
+func (f *Fluids) block(p BlockPos) (id byte, data byte, ok bool) {
+	if p.Y < 0 || p.Y >= ChunkSizeY {
+		return
+	}
+	chunk := f.world.Chunk(p.X>>4, p.Z>>4)
+	if chunk == nil {
+		return
+	}
+	x, y, z := int(p.X&15), int(p.Y), int(p.Z&15)
+	return chunk.Level.Block(x, y, z), chunk.Level.BlockData(x, y, z), true
+}
+
+// Returns the data of the fluid at p, or -1 if p isn't that fluid.
+func (f *Fluids) level(p BlockPos, fluid byte) int {
+	if id, data, ok := f.block(p); ok && fluidOf(id) == fluid {
+		return int(data)
+	}
+	return -1
+}
+
+func (f *Fluids) canFlowInto(p BlockPos) bool {
+	id, _, ok := f.block(p)
+	return ok && fluidOf(id) == registry.Air && !blocksFlow(id)
+}
+
+// Tells the simulation the block at p has changed, so it and any fluid beside it
+// move on their next chance.
+func (f *Fluids) Wake(p BlockPos) {
+	for _, q := range []BlockPos{p, p.offset(0, -1, 0), p.offset(0, 1, 0), p.offset(-1, 0, 0), p.offset(1, 0, 0), p.offset(0, 0, -1), p.offset(0, 0, 1)} {
+		if _, ok := f.due[q]; ok {
+			continue
+		}
+		if id, _, ok := f.block(q); ok && fluidOf(id) != registry.Air {
+			f.due[q] = f.ticks + fluidTickRate(fluidOf(id))
+		}
+	}
+}
+
+// Whether any fluid is waiting to move.
+func (f *Fluids) Busy() bool {
+	return len(f.due) > 0
+}
+
+type byY []BlockPos
+
+func (b byY) Len() int {
+	return len(b)
+}
+
+func (b byY) Less(i int, j int) bool {
+	if b[i].Y != b[j].Y {
+		return b[i].Y < b[j].Y
+	}
+	if b[i].Z != b[j].Z {
+		return b[i].Z < b[j].Z
+	}
+	return b[i].X < b[j].X
+}
+
+func (b byY) Swap(i int, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+// Moves on a tick, and returns every block that changed, in the order they changed.
+func (f *Fluids) Tick() (changes []BlockChange, err os.Error) {
+	f.ticks++
+	var now []BlockPos
+	for p, tick := range f.due {
+		if tick <= f.ticks {
+			now = append(now, p)
+		}
+	}
+	// the same world should always flow the same way.
+	sort.Sort(byY(now))
+	for _, p := range now {
+		f.due[p] = 0, false
+		if err = f.update(p, &changes); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (f *Fluids) set(p BlockPos, id byte, data byte, changes *[]BlockChange) (err os.Error) {
+	if err = f.world.SetBlock(p.X, p.Y, p.Z, id, data); err != nil {
+		return
+	}
+	*changes = append(*changes, BlockChange{p, id, data})
+	f.Wake(p)
+	return
+}
+
+// Lava touching water sets: a source into obsidian, and lava near one into
+// cobblestone.  Thinner lava, and falling lava, is left to flow.
+func (f *Fluids) harden(p BlockPos, data byte, changes *[]BlockChange) (hardened bool, err os.Error) {
+	for _, q := range []BlockPos{p.offset(0, 1, 0), p.offset(-1, 0, 0), p.offset(1, 0, 0), p.offset(0, 0, -1), p.offset(0, 0, 1)} {
+		if f.level(q, registry.Water) < 0 {
+			continue
+		}
+		switch {
+		case data == 0:
+			return true, f.set(p, registry.Obsidian, 0, changes)
+		case data <= 4:
+			return true, f.set(p, registry.Cobblestone, 0, changes)
+		}
+		return
+	}
+	return
+}
+
+// Moves the fluid at p: works out how deep it should be from what's around it,
+// then lets it fall, or failing that, spread.
+func (f *Fluids) update(p BlockPos, changes *[]BlockChange) (err os.Error) {
+	id, data, ok := f.block(p)
+	fluid := fluidOf(id)
+	if !ok || fluid == registry.Air {
+		// it changed since it was woken.
+		return
+	}
+	if fluid == registry.Lava {
+		if hardened, err := f.harden(p, data, changes); hardened || err != nil {
+			return err
+		}
+	}
+	decay := fluidDecay(fluid)
+	level := int(data)
+	if level != 0 {
+		// flowing fluid is one step thinner than its deepest neighbour, and lasts only
+		// as long as something feeds it.
+		sources, min := 0, -1
+		for _, q := range p.sides() {
+			d := f.level(q, fluid)
+			if d < 0 {
+				continue
+			}
+			if d == 0 {
+				sources++
+			}
+			if d >= fluidFalling {
+				d = 0
+			}
+			if min < 0 || d < min {
+				min = d
+			}
+		}
+		next := -1
+		if min >= 0 && min+decay < fluidFalling {
+			next = min + decay
+		}
+		if above := f.level(p.offset(0, 1, 0), fluid); above >= 0 {
+			next = above | fluidFalling
+		}
+		// water between two sources, on something it can't drain through, is a
+		// source itself.
+		if fluid == registry.Water && sources >= 2 {
+			below, _, ok := f.block(p.offset(0, -1, 0))
+			if ok && registry.GetBlock(below).Solid || f.level(p.offset(0, -1, 0), fluid) == 0 {
+				next = 0
+			}
+		}
+		if next < 0 {
+			return f.set(p, registry.Air, 0, changes)
+		}
+		if next != level {
+			level = next
+			if err = f.set(p, fluid, byte(level), changes); err != nil {
+				return
+			}
+		}
+	}
+	if level == int(data) && id != stillFluid(fluid) {
+		// it has settled.
+		if err = f.set(p, stillFluid(fluid), data, changes); err != nil {
+			return
+		}
+	}
+
+	below := p.offset(0, -1, 0)
+	if f.canFlowInto(below) {
+		return f.set(below, fluid, byte(level|fluidFalling), changes)
+	}
+	if belowId, _, ok := f.block(below); level != 0 && ok && !blocksFlow(belowId) {
+		// it's sitting on more fluid, which will do the spreading.
+		return
+	}
+	next := level + decay
+	if level >= fluidFalling {
+		next = 1
+	}
+	if next >= fluidFalling {
+		return
+	}
+	for _, q := range p.sides() {
+		if f.canFlowInto(q) {
+			if err = f.set(q, fluid, byte(next), changes); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
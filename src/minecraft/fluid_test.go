@@ -0,0 +1,139 @@
+package world
+
+import "minecraft/registry"
+
+import "testing"
+
+// Opens a flat world of stone up to y=1, with chunk (0, 0) loaded.
+func fluidWorld(t *testing.T, fixture *Fixture) *World {
+	w, err := fixture.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.LoadChunk(0, 0); err != nil {
+		w.Close()
+		t.Fatal(err)
+	}
+	return w
+}
+
+// Ticks until nothing is left to move, and returns everything that changed.
+func settle(t *testing.T, f *Fluids) (changes []BlockChange) {
+	for i := 0; f.Busy(); i++ {
+		if i == 10000 {
+			t.Fatal("fluids never settled")
+		}
+		c, err := f.Tick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, c...)
+	}
+	return
+}
+
+func expectBlock(t *testing.T, w *World, x int32, y int32, z int32, id byte, data byte) {
+	if i, d, err := w.Block(x, y, z); err != nil || i != id || d != data {
+		t.Errorf("expected %d:%d at (%d, %d, %d), got %d:%d (%v)", id, data, x, y, z, i, d, err)
+	}
+}
+
+func TestFluidSpread(t *testing.T) {
+	w := fluidWorld(t, NewFixture(1).Flat(0, 0, 0, registry.Bedrock, registry.Stone).
+		Block(8, 2, 8, registry.StillWater, 0).
+		Block(8, 2, 10, registry.Stone, 0))
+	defer w.Close()
+	f := NewFluids(w)
+	f.Wake(BlockPos{8, 2, 8})
+	changes := settle(t, f)
+	if len(changes) == 0 {
+		t.Fatal("expected the water to spread")
+	}
+
+	expectBlock(t, w, 8, 2, 8, registry.StillWater, 0)
+	expectBlock(t, w, 9, 2, 8, registry.StillWater, 1)
+	expectBlock(t, w, 15, 2, 8, registry.StillWater, 7)
+	expectBlock(t, w, 10, 2, 10, registry.StillWater, 4)
+	// round the stone in the way.
+	expectBlock(t, w, 8, 2, 11, registry.StillWater, 5)
+	// as far as it goes, and no further; not through the floor.
+	expectBlock(t, w, 1, 2, 8, registry.StillWater, 7)
+	expectBlock(t, w, 0, 2, 8, registry.Air, 0)
+	expectBlock(t, w, 8, 1, 8, registry.Stone, 0)
+
+	// take the source away, and the rest drains.
+	if err := w.SetBlock(8, 2, 8, registry.Air, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Wake(BlockPos{8, 2, 8})
+	settle(t, f)
+	for x := int32(0); x < 16; x++ {
+		for z := int32(0); z < 16; z++ {
+			if id, _, _ := w.Block(x, 2, z); fluidOf(id) != registry.Air {
+				t.Fatalf("expected the water to drain away, but (%d, 2, %d) is %d", x, z, id)
+			}
+		}
+	}
+}
+
+func TestFluidFall(t *testing.T) {
+	w := fluidWorld(t, NewFixture(1).Flat(0, 0, 0, registry.Bedrock, registry.Stone).
+		Block(8, 5, 8, registry.Stone, 0).
+		Block(8, 6, 8, registry.StillWater, 0))
+	defer w.Close()
+	f := NewFluids(w)
+	f.Wake(BlockPos{8, 6, 8})
+	settle(t, f)
+
+	// off the top of the pillar and down to the ground, where it spreads again.
+	expectBlock(t, w, 8, 6, 9, registry.StillWater, 1)
+	expectBlock(t, w, 8, 5, 9, registry.StillWater, 9)
+	expectBlock(t, w, 8, 2, 9, registry.StillWater, 9)
+	expectBlock(t, w, 8, 2, 12, registry.StillWater, 3)
+}
+
+func TestFluidInfiniteSource(t *testing.T) {
+	w := fluidWorld(t, NewFixture(1).Flat(0, 0, 0, registry.Bedrock, registry.Stone).
+		Block(5, 2, 5, registry.StillWater, 0).
+		Block(7, 2, 5, registry.StillWater, 0))
+	defer w.Close()
+	f := NewFluids(w)
+	f.Wake(BlockPos{5, 2, 5})
+	f.Wake(BlockPos{7, 2, 5})
+	settle(t, f)
+
+	expectBlock(t, w, 6, 2, 5, registry.StillWater, 0)
+	// a source on its own doesn't make another.
+	expectBlock(t, w, 4, 2, 5, registry.StillWater, 1)
+}
+
+func TestLavaMeetsWater(t *testing.T) {
+	w := fluidWorld(t, NewFixture(1).Flat(0, 0, 0, registry.Bedrock, registry.Stone).
+		Block(4, 2, 4, registry.StillLava, 0).
+		Block(5, 2, 4, registry.StillWater, 0).
+		Block(10, 2, 2, registry.Lava, 2).
+		Block(11, 2, 2, registry.StillWater, 0).
+		Block(10, 2, 12, registry.Lava, 6).
+		Block(11, 2, 12, registry.StillWater, 0))
+	defer w.Close()
+	f := NewFluids(w)
+	for _, p := range []BlockPos{{4, 2, 4}, {5, 2, 4}, {10, 2, 2}, {11, 2, 2}, {10, 2, 12}, {11, 2, 12}} {
+		f.Wake(p)
+	}
+	changes := settle(t, f)
+
+	expectBlock(t, w, 4, 2, 4, registry.Obsidian, 0)
+	expectBlock(t, w, 10, 2, 2, registry.Cobblestone, 0)
+	if id, _, _ := w.Block(10, 2, 12); id == registry.Cobblestone || fluidOf(id) == registry.Lava {
+		t.Error("expected thin lava with no source to drain away, got ", id)
+	}
+	obsidian := false
+	for _, c := range changes {
+		if c.Pos == (BlockPos{4, 2, 4}) && c.Id == registry.Obsidian {
+			obsidian = true
+		}
+	}
+	if !obsidian {
+		t.Error("expected a change to obsidian among ", changes)
+	}
+}
@@ -0,0 +1,163 @@
+package world
+
+import "minecraft/registry"
+
+import "os"
+import "rand"
+
+// A Generator produces terrain for chunks that don't exist on disk yet.
+// Generators must be deterministic: the same chunk coordinates always
+// produce the same chunk, no matter the order they are asked for in.
+type Generator interface {
+	Generate(x int32, z int32) (*Chunk, os.Error)
+}
+
+const (
+	seaLevel = 64
+	// roughly how far terrain strays from sea level, in blocks.
+	hillHeight   = 24
+	detailHeight = 6
+)
+
+// The default generator: rolling noise-based hills, a sea at y=64, a sprinkling of
+// caves and some trees.  Not the terrain Minecraft itself would generate from the
+// same seed, but stable for a given seed.
+type DefaultGenerator struct {
+	seed   int64
+	hills  *perlin
+	detail *perlin
+	caves  *perlin
+}
+
+func NewDefaultGenerator(seed int64) *DefaultGenerator {
+	r := rand.New(rand.NewSource(seed))
+	return &DefaultGenerator{
+		seed:   seed,
+		hills:  newPerlin(r),
+		detail: newPerlin(r),
+		caves:  newPerlin(r),
+	}
+}
+
+// Every chunk gets its own random source for decorations, derived from the
+// world seed and its position (the same way Minecraft seeds its populators).
+func (g *DefaultGenerator) chunkRand(x int32, z int32) *rand.Rand {
+	return rand.New(rand.NewSource(g.seed ^ int64(x)*341873128712 ^ int64(z)*132897987541))
+}
+
+func (g *DefaultGenerator) surfaceHeight(wx int64, wz int64) int {
+	h := float64(seaLevel) +
+		g.hills.octaves2(float64(wx)/128, float64(wz)/128, 4)*hillHeight +
+		g.detail.noise2(float64(wx)/24, float64(wz)/24)*detailHeight
+	if h < 4 {
+		h = 4
+	}
+	if h > ChunkSizeY-16 {
+		h = ChunkSizeY - 16
+	}
+	return int(h)
+}
+
+func (g *DefaultGenerator) isCave(wx int64, y int, wz int64) bool {
+	n := g.caves.noise3(float64(wx)/32, float64(y)/16, float64(wz)/32)
+	return n > -0.06 && n < 0.06
+}
+
+func (g *DefaultGenerator) Generate(x int32, z int32) (chunk *Chunk, err os.Error) {
+	chunk = &Chunk{Level: newLevel(x, z)}
+	level := &chunk.Level
+	for cx := 0; cx < ChunkSizeX; cx++ {
+		for cz := 0; cz < ChunkSizeZ; cz++ {
+			wx, wz := int64(x)*ChunkSizeX+int64(cx), int64(z)*ChunkSizeZ+int64(cz)
+			g.generateColumn(level, cx, cz, wx, wz)
+		}
+	}
+	g.plantTrees(level, g.chunkRand(x, z))
+	level.computeHeightMap()
+	level.computeLight()
+	level.TerrainPopulated = 1
+	return
+}
+
+func (g *DefaultGenerator) generateColumn(level *Level, cx int, cz int, wx int64, wz int64) {
+	height := g.surfaceHeight(wx, wz)
+	beach := height <= seaLevel+1
+	for y := 0; y < ChunkSizeY; y++ {
+		var id byte
+		switch {
+		case y == 0:
+			id = registry.Bedrock
+		case y < height-4:
+			id = registry.Stone
+		case y < height-1:
+			id = registry.Dirt
+			if beach {
+				id = registry.Sand
+			}
+		case y == height-1:
+			switch {
+			case height < seaLevel-4:
+				id = registry.Gravel
+			case beach:
+				id = registry.Sand
+			default:
+				id = registry.Grass
+			}
+		case y < seaLevel:
+			id = registry.StillWater
+		default:
+			id = registry.Air
+		}
+		// carve caves, but never through the floor or up into the sea.
+		if y > 4 && y < height && id != registry.Bedrock && height > seaLevel && g.isCave(wx, y, wz) {
+			id = registry.Air
+		}
+		level.SetBlock(cx, y, cz, id)
+	}
+}
+
+func (g *DefaultGenerator) plantTrees(level *Level, r *rand.Rand) {
+	trees := r.Intn(4)
+	for i := 0; i < trees; i++ {
+		// keep the canopy inside this chunk, so generating a chunk never
+		// has to touch its neighbours.
+		x, z := 2+r.Intn(ChunkSizeX-4), 2+r.Intn(ChunkSizeZ-4)
+		y := ChunkSizeY - 1
+		for y > 0 && level.Block(x, y, z) == registry.Air {
+			y--
+		}
+		if level.Block(x, y, z) != registry.Grass {
+			continue
+		}
+		g.plantTree(level, r, x, y+1, z)
+	}
+}
+
+func (g *DefaultGenerator) plantTree(level *Level, r *rand.Rand, x int, y int, z int) {
+	trunk := 4 + r.Intn(3)
+	if y+trunk+2 >= ChunkSizeY {
+		return
+	}
+	level.SetBlock(x, y-1, z, registry.Dirt)
+	top := y + trunk
+	for ly := top - 3; ly <= top; ly++ {
+		radius := 2
+		if ly >= top-1 {
+			radius = 1
+		}
+		for lx := x - radius; lx <= x+radius; lx++ {
+			for lz := z - radius; lz <= z+radius; lz++ {
+				// round off the corners, a bit randomly.
+				if (lx-x)*(lx-x) == radius*radius && (lz-z)*(lz-z) == radius*radius && r.Intn(2) == 0 {
+					continue
+				}
+				if level.Block(lx, ly, lz) == registry.Air {
+					level.SetBlock(lx, ly, lz, registry.Leaves)
+				}
+			}
+		}
+	}
+	for ty := y; ty < top; ty++ {
+		level.SetBlock(x, ty, z, registry.Log)
+	}
+}
@@ -0,0 +1,50 @@
+package world
+
+import "minecraft/registry"
+
+import "bytes"
+import "testing"
+
+func TestGeneratorDeterministic(t *testing.T) {
+	a, err := NewDefaultGenerator(42).Generate(3, -7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewDefaultGenerator(42)
+	g.Generate(0, 0) // asking for other chunks first mustn't change anything
+	b, err := g.Generate(3, -7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Level.Blocks, b.Level.Blocks) {
+		t.Error("same seed and coordinates generated different blocks")
+	}
+	if a.Level.XPos != 3 || a.Level.ZPos != -7 {
+		t.Error("expected chunk at (3, -7), got ", a.Level.XPos, a.Level.ZPos)
+	}
+	if a.Level.TerrainPopulated != 1 {
+		t.Error("generated chunk should be marked populated")
+	}
+}
+
+func TestGeneratorLayers(t *testing.T) {
+	chunk, err := NewDefaultGenerator(1).Generate(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &chunk.Level
+	for x := 0; x < ChunkSizeX; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			if l.Block(x, 0, z) != registry.Bedrock {
+				t.Fatalf("expected bedrock at the bottom of (%d, %d)", x, z)
+			}
+			h := l.Height(x, z)
+			if h == 0 || registry.GetBlock(l.Block(x, h-1, z)).Opacity == 0 {
+				t.Fatalf("height map at (%d, %d) doesn't sit on a block", x, z)
+			}
+			if l.SkyLightAt(x, h, z) != 15 || (h > 1 && l.SkyLightAt(x, 1, z) != 0) {
+				t.Fatalf("sky light in column (%d, %d) doesn't follow the height map", x, z)
+			}
+		}
+	}
+}
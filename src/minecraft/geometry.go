@@ -0,0 +1,219 @@
+package world
+
+import "minecraft/registry"
+
+import "math"
+import "os"
+
+// A box lined up with the axes, in world coordinates.  Boxes that only touch don't
+// overlap.
+type AABB struct {
+	Min, Max Position
+}
+
+func (b AABB) Intersects(o AABB) bool {
+	return b.Min.X < o.Max.X && b.Max.X > o.Min.X &&
+		b.Min.Y < o.Max.Y && b.Max.Y > o.Min.Y &&
+		b.Min.Z < o.Max.Z && b.Max.Z > o.Min.Z
+}
+
+// Whether p is in the box, counting its lowest sides but not its highest, so
+// neighbouring boxes never both contain a point.
+func (b AABB) Contains(p Position) bool {
+	return p.X >= b.Min.X && p.X < b.Max.X &&
+		p.Y >= b.Min.Y && p.Y < b.Max.Y &&
+		p.Z >= b.Min.Z && p.Z < b.Max.Z
+}
+
+// The box taken up by the block at p.
+func BlockBox(p BlockPos) AABB {
+	return AABB{
+		Position{float64(p.X), float64(p.Y), float64(p.Z)},
+		Position{float64(p.X + 1), float64(p.Y + 1), float64(p.Z + 1)},
+	}
+}
+
+// A side of a block, numbered as the protocol numbers them for digging and placing.
+type Face int8
+
+// A ray that starts inside a solid block hits it on no face at all.
+const NoFace Face = -1
+
+const (
+	FaceNegY Face = iota
+	FacePosY
+	FaceNegZ
+	FacePosZ
+	FaceNegX
+	FacePosX
+)
+
+// Which way the face points, one block.
+func (f Face) Offset() BlockPos {
+	switch f {
+	case FaceNegY:
+		return BlockPos{0, -1, 0}
+	case FacePosY:
+		return BlockPos{0, 1, 0}
+	case FaceNegZ:
+		return BlockPos{0, 0, -1}
+	case FacePosZ:
+		return BlockPos{0, 0, 1}
+	case FaceNegX:
+		return BlockPos{-1, 0, 0}
+	case FacePosX:
+		return BlockPos{1, 0, 0}
+	}
+	return BlockPos{}
+}
+
+// Where a ray first hit a solid block.
+type RayHit struct {
+	Block BlockPos
+	Id    byte
+	// the side of the block the ray came in through.
+	Face Face
+	// the exact point the ray reached the block.
+	Position Position
+}
+
+// The block beside the face that was hit, which is where a block placed against
+// it goes.
+func (h *RayHit) Adjacent() BlockPos {
+	o := h.Face.Offset()
+	return BlockPos{h.Block.X + o.X, h.Block.Y + o.Y, h.Block.Z + o.Z}
+}
+
+// Puts the hit exactly on the face, which adding up steps along the ray misses by a
+// little.
+func (h *RayHit) snap() {
+	switch h.Face {
+	case FaceNegY:
+		h.Position.Y = float64(h.Block.Y)
+	case FacePosY:
+		h.Position.Y = float64(h.Block.Y + 1)
+	case FaceNegZ:
+		h.Position.Z = float64(h.Block.Z)
+	case FacePosZ:
+		h.Position.Z = float64(h.Block.Z + 1)
+	case FaceNegX:
+		h.Position.X = float64(h.Block.X)
+	case FacePosX:
+		h.Position.X = float64(h.Block.X + 1)
+	}
+}
+
+// Sets up stepping along one axis of a ray: which way the block coordinate goes,
+// how far along the ray (as a fraction of it) the first block boundary is, and how
+// far apart the boundaries after that are.
+func rayAxis(from float64, d float64) (step int32, next float64, delta float64) {
+	switch {
+	case d > 0:
+		return 1, (math.Floor(from) + 1 - from) / d, 1 / d
+	case d < 0:
+		return -1, (from - math.Floor(from)) / -d, 1 / -d
+	}
+	return 0, math.Inf(1), math.Inf(1)
+}
+
+// Follows the line from from to to, block by block, and returns the first solid
+// block it goes into, or nil if it gets to to without hitting one.  Chunks along
+// the way are loaded if they need to be.  Above and below the world there is
+// nothing to hit.
+func (world *World) Raycast(from Position, to Position) (hit *RayHit, err os.Error) {
+	dx, dy, dz := to.X-from.X, to.Y-from.Y, to.Z-from.Z
+	x, y, z := int32(math.Floor(from.X)), int32(math.Floor(from.Y)), int32(math.Floor(from.Z))
+	stepX, nextX, deltaX := rayAxis(from.X, dx)
+	stepY, nextY, deltaY := rayAxis(from.Y, dy)
+	stepZ, nextZ, deltaZ := rayAxis(from.Z, dz)
+	face, t := NoFace, 0.0
+	for {
+		if y >= 0 && y < ChunkSizeY {
+			var id byte
+			if id, _, err = world.Block(x, y, z); err != nil {
+				return
+			}
+			if registry.GetBlock(id).Solid {
+				hit = &RayHit{BlockPos{x, y, z}, id, face, Position{from.X + dx*t, from.Y + dy*t, from.Z + dz*t}}
+				hit.snap()
+				return
+			}
+		}
+		// step into whichever neighbour the ray reaches first.
+		switch {
+		case nextX <= nextY && nextX <= nextZ:
+			t, x, nextX = nextX, x+stepX, nextX+deltaX
+			face = FaceNegX
+			if stepX < 0 {
+				face = FacePosX
+			}
+		case nextY <= nextZ:
+			t, y, nextY = nextY, y+stepY, nextY+deltaY
+			face = FaceNegY
+			if stepY < 0 {
+				face = FacePosY
+			}
+		default:
+			t, z, nextZ = nextZ, z+stepZ, nextZ+deltaZ
+			face = FaceNegZ
+			if stepZ < 0 {
+				face = FacePosZ
+			}
+		}
+		if t > 1 {
+			return
+		}
+	}
+	panic("unreachable")
+}
+
+// Returns every solid block that overlaps box, loading chunks if they need to be.
+// Entities moving by a step can check the box they would end up in.
+func (world *World) Collisions(box AABB) (solid []BlockPos, err os.Error) {
+	x0, x1 := int32(math.Floor(box.Min.X)), int32(math.Ceil(box.Max.X))-1
+	y0, y1 := int32(math.Floor(box.Min.Y)), int32(math.Ceil(box.Max.Y))-1
+	z0, z1 := int32(math.Floor(box.Min.Z)), int32(math.Ceil(box.Max.Z))-1
+	if y0 < 0 {
+		y0 = 0
+	}
+	if y1 >= ChunkSizeY {
+		y1 = ChunkSizeY - 1
+	}
+	for x := x0; x <= x1; x++ {
+		for z := z0; z <= z1; z++ {
+			for y := y0; y <= y1; y++ {
+				id, _, err := world.Block(x, y, z)
+				if err != nil {
+					return nil, err
+				}
+				if registry.GetBlock(id).Solid {
+					solid = append(solid, BlockPos{x, y, z})
+				}
+			}
+		}
+	}
+	return
+}
+
+// Returns the entities whose positions are in box, from chunks that are already
+// loaded; nothing is loaded to answer.
+func (world *World) EntitiesIn(box AABB) (entities []Entity) {
+	cx0, cx1 := int32(math.Floor(box.Min.X))>>4, int32(math.Floor(box.Max.X))>>4
+	cz0, cz1 := int32(math.Floor(box.Min.Z))>>4, int32(math.Floor(box.Max.Z))>>4
+	world.chunkLock.Lock()
+	defer world.chunkLock.Unlock()
+	for cx := cx0; cx <= cx1; cx++ {
+		for cz := cz0; cz <= cz1; cz++ {
+			chunk, ok := world.Chunks[MakeXZ(cx, cz)]
+			if !ok {
+				continue
+			}
+			for _, e := range chunk.Level.Entities {
+				if box.Contains(e.Base().Physics.Position) {
+					entities = append(entities, e)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package world
+
+import "minecraft/registry"
+
+import "testing"
+
+func TestRaycast(t *testing.T) {
+	w, err := NewFixture(1).Flat(0, 0, 1, registry.Bedrock, registry.Stone, registry.Stone, registry.Grass).
+		Block(4, 5, 0, registry.Glass, 0).
+		Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, c := range []struct {
+		from, to Position
+		block    BlockPos
+		face     Face
+		at       Position
+	}{
+		// looking straight down at the ground.
+		{Position{0.5, 10, 0.5}, Position{0.5, 0, 0.5}, BlockPos{0, 3, 0}, FacePosY, Position{0.5, 4, 0.5}},
+		// at the glass from either side.
+		{Position{0.5, 5.5, 0.5}, Position{10.5, 5.5, 0.5}, BlockPos{4, 5, 0}, FaceNegX, Position{4, 5.5, 0.5}},
+		{Position{10.5, 5.5, 0.5}, Position{0.5, 5.5, 0.5}, BlockPos{4, 5, 0}, FacePosX, Position{5, 5.5, 0.5}},
+		// starting in the ground.
+		{Position{0.5, 1.5, 0.5}, Position{0.5, 10, 0.5}, BlockPos{0, 1, 0}, NoFace, Position{0.5, 1.5, 0.5}},
+		// down into a negative chunk at an angle.
+		{Position{-0.5, 5, 0.5}, Position{-4.5, 1, 0.5}, BlockPos{-2, 3, 0}, FacePosY, Position{-1.5, 4, 0.5}},
+	} {
+		hit, err := w.Raycast(c.from, c.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hit == nil {
+			t.Errorf("ray from %v to %v: expected a hit", c.from, c.to)
+			continue
+		}
+		if hit.Block != c.block || hit.Face != c.face || hit.Position != c.at {
+			t.Errorf("ray from %v to %v: expected %v face %d at %v, got %v face %d at %v", c.from, c.to, c.block, c.face, c.at, hit.Block, hit.Face, hit.Position)
+		}
+	}
+
+	hit, err := w.Raycast(Position{0.5, 10.5, 0.5}, Position{10.5, 10.5, 0.5})
+	if err != nil || hit != nil {
+		t.Errorf("expected a ray through the air to miss, got %v (%v)", hit, err)
+	}
+	// stops at the end, just short of the ground.
+	hit, err = w.Raycast(Position{0.5, 10, 0.5}, Position{0.5, 4.1, 0.5})
+	if err != nil || hit != nil {
+		t.Errorf("expected a ray ending above the ground to miss, got %v (%v)", hit, err)
+	}
+
+	hit, _ = w.Raycast(Position{0.5, 10, 0.5}, Position{0.5, 0, 0.5})
+	if adjacent := hit.Adjacent(); adjacent != (BlockPos{0, 4, 0}) {
+		t.Error("expected a block placed on the ground to go at (0, 4, 0), got ", adjacent)
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	w, err := NewFixture(1).Flat(0, 0, 1, registry.Bedrock, registry.Stone, registry.Stone, registry.Grass).
+		Block(4, 5, 0, registry.Glass, 0).
+		Block(6, 4, 0, registry.Rose, 0).
+		Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// a player-sized box.
+	box := func(x float64, y float64, z float64) AABB {
+		return AABB{Position{x - 0.3, y, z - 0.3}, Position{x + 0.3, y + 1.8, z + 0.3}}
+	}
+	for _, c := range []struct {
+		box      AABB
+		expected []BlockPos
+	}{
+		// standing on the ground only touches it.
+		{box(0.5, 4, 0.5), nil},
+		{box(0.5, 3.5, 0.5), []BlockPos{{0, 3, 0}}},
+		{box(4, 5, 0.5), []BlockPos{{4, 5, 0}}},
+		// flowers don't get in the way.
+		{box(6.5, 4, 0.5), nil},
+		// the top of the world, and above it.
+		{AABB{Position{0, 126, 0}, Position{1, 140, 1}}, nil},
+	} {
+		solid, err := w.Collisions(c.box)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(solid) != len(c.expected) {
+			t.Errorf("box %v: expected %v, got %v", c.box, c.expected, solid)
+			continue
+		}
+		for i := range solid {
+			if solid[i] != c.expected[i] {
+				t.Errorf("box %v: expected %v, got %v", c.box, c.expected, solid)
+			}
+		}
+	}
+
+	if !BlockBox(BlockPos{0, 3, 0}).Intersects(box(0.5, 3.5, 0.5)) || BlockBox(BlockPos{0, 3, 0}).Intersects(box(0.5, 4, 0.5)) {
+		t.Error("expected boxes to intersect only when they overlap")
+	}
+}
+
+func TestEntitiesIn(t *testing.T) {
+	w, err := NewFixture(1).Flat(0, 0, 1, registry.Bedrock, registry.Grass).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err = w.LoadChunk(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	pig := &Pig{}
+	pig.Physics.Position = Position{2.5, 2, 2.5}
+	chunk := w.Chunk(0, 0)
+	chunk.Level.Entities = append(chunk.Level.Entities, pig)
+
+	if found := w.EntitiesIn(AABB{Position{2, 2, 2}, Position{3, 4, 3}}); len(found) != 1 || found[0] != Entity(pig) {
+		t.Error("expected to find the pig, got ", found)
+	}
+	if found := w.EntitiesIn(AABB{Position{3, 2, 3}, Position{4, 4, 4}}); len(found) != 0 {
+		t.Error("expected nothing beside the pig, got ", found)
+	}
+	// (-1, 0) was never loaded, so isn't searched.
+	if found := w.EntitiesIn(AABB{Position{-32, 0, -32}, Position{32, 128, 32}}); len(found) != 1 {
+		t.Error("expected just the pig in loaded chunks, got ", found)
+	}
+	if w.Chunk(-1, 0) != nil {
+		t.Error("expected EntitiesIn not to load chunks")
+	}
+}
@@ -0,0 +1,314 @@
+package world
+
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// Lighting works the way Minecraft's does: every block has a sky light and a block
+// light level from 0 to 15.  Sky light is 15 anywhere the sky can be seen straight
+// up (at or above the height map) and block light is whatever the block itself
+// emits.  From there, light spreads to each neighbour, losing the neighbour's
+// opacity (but at least 1) on the way.
+
+const maxLight = 15
+
+type lightNode struct {
+	x     int32
+	y     int
+	z     int32
+	light byte
+}
+
+// A lighter flood fills one kind of light across whatever chunks its lookup
+// function can find.  Blocks in chunks it can't find are treated as if they
+// don't exist, so light stops at the edge of the loaded world.
+type lighter struct {
+	sky     bool
+	lookup  func(cx int32, cz int32) *Level
+	touched map[XZ]bool
+	// most lookups land in the same chunk as the last one.
+	last         *Level
+	lastX, lastZ int32
+}
+
+func newLighter(sky bool, lookup func(cx int32, cz int32) *Level) *lighter {
+	return &lighter{sky: sky, lookup: lookup, touched: make(map[XZ]bool)}
+}
+
+func (lt *lighter) level(x int32, y int, z int32) (l *Level, lx int, lz int) {
+	if y < 0 || y >= ChunkSizeY {
+		return
+	}
+	cx, cz := x>>4, z>>4
+	if lt.last == nil || lt.lastX != cx || lt.lastZ != cz {
+		if lt.last = lt.lookup(cx, cz); lt.last == nil {
+			return
+		}
+		lt.lastX, lt.lastZ = cx, cz
+	}
+	return lt.last, int(x & 15), int(z & 15)
+}
+
+func (lt *lighter) get(x int32, y int, z int32) (light byte, ok bool) {
+	l, lx, lz := lt.level(x, y, z)
+	if l == nil {
+		return
+	}
+	if lt.sky {
+		return l.SkyLightAt(lx, y, lz), true
+	}
+	return l.BlockLightAt(lx, y, lz), true
+}
+
+func (lt *lighter) set(x int32, y int, z int32, light byte) {
+	l, lx, lz := lt.level(x, y, z)
+	if l == nil {
+		return
+	}
+	if lt.sky {
+		l.SetSkyLight(lx, y, lz, light)
+	} else {
+		l.SetBlockLight(lx, y, lz, light)
+	}
+	lt.touched[MakeXZ(lt.lastX, lt.lastZ)] = true
+}
+
+// The light a block has regardless of its neighbours.
+func (lt *lighter) source(x int32, y int, z int32) byte {
+	l, lx, lz := lt.level(x, y, z)
+	if l == nil {
+		return 0
+	}
+	if lt.sky {
+		if y >= l.Height(lx, lz) {
+			return maxLight
+		}
+		return 0
+	}
+	return registry.GetBlock(l.Block(lx, y, lz)).Light
+}
+
+// How much light is lost moving into a block.
+func (lt *lighter) cost(x int32, y int, z int32) byte {
+	l, lx, lz := lt.level(x, y, z)
+	if l == nil {
+		return maxLight
+	}
+	if op := registry.GetBlock(l.Block(lx, y, lz)).Opacity; op > 1 {
+		return op
+	}
+	return 1
+}
+
+var neighbours = [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// Spreads light outward from the queued blocks until it runs out.
+func (lt *lighter) spread(queue []lightNode) {
+	for len(queue) > 0 {
+		n := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		light, ok := lt.get(n.x, n.y, n.z)
+		if !ok || light <= 1 {
+			continue
+		}
+		for _, d := range neighbours {
+			x, y, z := n.x+int32(d[0]), n.y+d[1], n.z+int32(d[2])
+			nl, ok := lt.get(x, y, z)
+			if !ok {
+				continue
+			}
+			cost := lt.cost(x, y, z)
+			if light > cost && nl < light-cost {
+				lt.set(x, y, z, light-cost)
+				queue = append(queue, lightNode{x, y, z, light - cost})
+			}
+		}
+	}
+}
+
+// Removes the light that used to spread out from the queued blocks (whose light
+// fields hold what their level was).  Returns the blocks whose light came from
+// somewhere else, which need to spread again to fill the hole back in.
+func (lt *lighter) unspread(queue []lightNode) (relight []lightNode) {
+	for _, n := range queue {
+		lt.darken(n.x, n.y, n.z, &relight)
+	}
+	for len(queue) > 0 {
+		n := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, d := range neighbours {
+			x, y, z := n.x+int32(d[0]), n.y+d[1], n.z+int32(d[2])
+			nl, ok := lt.get(x, y, z)
+			if !ok || nl == 0 {
+				continue
+			}
+			if nl < n.light {
+				lt.darken(x, y, z, &relight)
+				queue = append(queue, lightNode{x, y, z, nl})
+			} else {
+				relight = append(relight, lightNode{x, y, z, nl})
+			}
+		}
+	}
+	return
+}
+
+func (lt *lighter) darken(x int32, y int, z int32, relight *[]lightNode) {
+	src := lt.source(x, y, z)
+	lt.set(x, y, z, src)
+	if src > 0 {
+		*relight = append(*relight, lightNode{x, y, z, src})
+	}
+}
+
+func (l *Level) lookupSelf(cx int32, cz int32) *Level {
+	if cx == l.XPos && cz == l.ZPos {
+		return l
+	}
+	return nil
+}
+
+// Lights the chunk from scratch, as if it had no neighbours.
+func (l *Level) computeLight() {
+	l.computeSkyLight()
+	l.computeBlockLight()
+}
+
+func (l *Level) computeSkyLight() {
+	var queue []lightNode
+	x0, z0 := l.XPos*ChunkSizeX, l.ZPos*ChunkSizeZ
+	for x := 0; x < ChunkSizeX; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			h := l.Height(x, z)
+			for y := 0; y < ChunkSizeY; y++ {
+				var light byte
+				if y >= h {
+					light = maxLight
+				}
+				l.SetSkyLight(x, y, z, light)
+			}
+			// only the sky light next to a taller column (or just above the
+			// ground) can go anywhere that isn't already fully lit.
+			top := h
+			for _, d := range neighbours {
+				if nx, nz := x+d[0], z+d[2]; inChunk(nx, 0, nz) && l.Height(nx, nz) > top {
+					top = l.Height(nx, nz)
+				}
+			}
+			for y := h; y <= top && y < ChunkSizeY; y++ {
+				queue = append(queue, lightNode{x0 + int32(x), y, z0 + int32(z), maxLight})
+			}
+		}
+	}
+	newLighter(true, func(cx int32, cz int32) *Level { return l.lookupSelf(cx, cz) }).spread(queue)
+}
+
+func (l *Level) computeBlockLight() {
+	var queue []lightNode
+	x0, z0 := l.XPos*ChunkSizeX, l.ZPos*ChunkSizeZ
+	for x := 0; x < ChunkSizeX; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			for y := 0; y < ChunkSizeY; y++ {
+				e := registry.GetBlock(l.Block(x, y, z)).Light
+				l.SetBlockLight(x, y, z, e)
+				if e > 0 {
+					queue = append(queue, lightNode{x0 + int32(x), y, z0 + int32(z), e})
+				}
+			}
+		}
+	}
+	newLighter(false, func(cx int32, cz int32) *Level { return l.lookupSelf(cx, cz) }).spread(queue)
+}
+
+// Looks up loaded chunks for a lighter.  Chunks that aren't loaded are left alone.
+func (world *World) lookupLevel(cx int32, cz int32) *Level {
+	if chunk := world.Chunk(cx, cz); chunk != nil {
+		return &chunk.Level
+	}
+	return nil
+}
+
+func (world *World) markDirty(touched map[XZ]bool) {
+	world.chunkLock.Lock()
+	defer world.chunkLock.Unlock()
+	for xz := range touched {
+		if chunk, ok := world.Chunks[xz]; ok {
+			chunk.dirty = true
+		}
+	}
+}
+
+// Fixes up lighting around a block that has just changed, including in any loaded
+// neighbouring chunks the change reaches.
+func (world *World) relight(x int32, y int, z int32) {
+	lookup := func(cx int32, cz int32) *Level { return world.lookupLevel(cx, cz) }
+	l := world.lookupLevel(x>>4, z>>4)
+	lx, lz := int(x&15), int(z&15)
+
+	// sky light: the change may have moved the height map, exposing or covering
+	// part of the column.
+	sky := newLighter(true, lookup)
+	oldh := l.Height(lx, lz)
+	l.computeHeight(lx, lz)
+	newh := l.Height(lx, lz)
+	covered := []lightNode{{x, y, z, l.SkyLightAt(lx, y, lz)}}
+	for cy := oldh; cy < newh; cy++ {
+		covered = append(covered, lightNode{x, cy, z, l.SkyLightAt(lx, cy, lz)})
+	}
+	relit := sky.unspread(covered)
+	for cy := newh; cy < oldh; cy++ {
+		sky.set(x, cy, z, maxLight)
+		relit = append(relit, lightNode{x, cy, z, maxLight})
+	}
+	sky.spread(relit)
+	world.markDirty(sky.touched)
+
+	block := newLighter(false, lookup)
+	block.spread(block.unspread([]lightNode{{x, y, z, l.BlockLightAt(lx, y, lz)}}))
+	world.markDirty(block.touched)
+}
+
+func blockOutOfRange(x int32, y int32, z int32) os.Error {
+	return error.NewError(fmt.Sprintf("block (%d, %d, %d) is outside the world", x, y, z), nil)
+}
+
+// Returns the block id and data at world coordinates (x, y, z), loading its chunk if needed.
+func (world *World) Block(x int32, y int32, z int32) (id byte, data byte, err os.Error) {
+	if y < 0 || y >= ChunkSizeY {
+		err = blockOutOfRange(x, y, z)
+		return
+	}
+	chunk, err := world.LoadChunkAsync(x>>4, z>>4).Wait()
+	if err != nil {
+		return
+	}
+	lx, lz := int(x&15), int(z&15)
+	id = chunk.Level.Block(lx, int(y), lz)
+	data = chunk.Level.BlockData(lx, int(y), lz)
+	return
+}
+
+// Changes the block at world coordinates (x, y, z), loading its chunk if needed,
+// and relights everything the change affects.  Not safe to call from several
+// goroutines at once.
+func (world *World) SetBlock(x int32, y int32, z int32, id byte, data byte) (err os.Error) {
+	if y < 0 || y >= ChunkSizeY {
+		return blockOutOfRange(x, y, z)
+	}
+	chunk, err := world.LoadChunkAsync(x>>4, z>>4).Wait()
+	if err != nil {
+		return
+	}
+	l := &chunk.Level
+	lx, ly, lz := int(x&15), int(y), int(z&15)
+	old := l.Block(lx, ly, lz)
+	l.SetBlock(lx, ly, lz, id)
+	l.SetBlockData(lx, ly, lz, data)
+	world.markChunkDirty(chunk)
+	if before, after := registry.GetBlock(old), registry.GetBlock(id); before.Opacity != after.Opacity || before.Light != after.Light {
+		world.relight(x, ly, z)
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package world
+
+import "minecraft/registry"
+
+import "testing"
+
+func TestComputeBlockLight(t *testing.T) {
+	chunk := &Chunk{Level: newLevel(0, 0)}
+	l := &chunk.Level
+	for x := 0; x < ChunkSizeX; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			l.SetBlock(x, 0, z, registry.Stone)
+		}
+	}
+	l.SetBlock(8, 1, 8, registry.Torch)
+	l.computeHeightMap()
+	l.computeLight()
+
+	if got := l.BlockLightAt(8, 1, 8); got != 14 {
+		t.Error("expected the torch to have light 14, got ", got)
+	}
+	if got := l.BlockLightAt(11, 1, 8); got != 11 {
+		t.Error("expected light 11 three blocks from the torch, got ", got)
+	}
+	if got := l.BlockLightAt(8, 0, 8); got != 0 {
+		t.Error("expected no light inside stone, got ", got)
+	}
+	if got := l.SkyLightAt(3, 1, 3); got != 15 {
+		t.Error("expected full sky light on an open floor, got ", got)
+	}
+}
+
+func TestComputeSkyLightUnderOverhang(t *testing.T) {
+	chunk := &Chunk{Level: newLevel(0, 0)}
+	l := &chunk.Level
+	// a roof over x < 8 at y = 10
+	for x := 0; x < 8; x++ {
+		for z := 0; z < ChunkSizeZ; z++ {
+			l.SetBlock(x, 10, z, registry.Stone)
+		}
+	}
+	l.computeHeightMap()
+	l.computeLight()
+
+	if got := l.SkyLightAt(7, 5, 4); got != 14 {
+		t.Error("expected light 14 just under the edge of the roof, got ", got)
+	}
+	if got := l.SkyLightAt(4, 5, 4); got != 11 {
+		t.Error("expected light 11 four blocks under the roof, got ", got)
+	}
+}
@@ -0,0 +1,195 @@
+package world
+
+import "minecraft/error"
+
+import "os"
+import "sync"
+
+const (
+	// Chunk loading is dominated by disk reads and gunzipping, so a handful of
+	// workers is plenty to keep the disk busy without thrashing it.
+	loaderWorkers = 4
+	// Requests beyond this many block the caller until a worker catches up.
+	loaderQueueLen = 1024
+)
+
+// A ChunkFuture is the pending result of LoadChunkAsync.
+type ChunkFuture struct {
+	X, Z  int32
+	done  chan bool
+	chunk *Chunk
+	err   os.Error
+}
+
+// Returns a channel that is closed once the chunk has been loaded (or failed to load).
+func (f *ChunkFuture) Done() <-chan bool {
+	return f.done
+}
+
+// Blocks until the chunk has been loaded.
+func (f *ChunkFuture) Wait() (*Chunk, os.Error) {
+	<-f.done
+	return f.chunk, f.err
+}
+
+func (f *ChunkFuture) finish(chunk *Chunk, err os.Error) {
+	f.chunk, f.err = chunk, err
+	close(f.done)
+}
+
+// The chunk loader hands chunk reads off to a fixed pool of workers.  Requests
+// for a chunk that is already on its way share the same future, so the chunk
+// is only ever read once.
+type chunkLoader struct {
+	world   *World
+	lock    sync.Mutex
+	pending map[XZ]*ChunkFuture
+	queue   chan *ChunkFuture
+	quit    chan bool
+	stopped bool
+}
+
+func newChunkLoader(world *World, workers int) *chunkLoader {
+	l := &chunkLoader{
+		world:   world,
+		pending: make(map[XZ]*ChunkFuture),
+		queue:   make(chan *ChunkFuture, loaderQueueLen),
+		quit:    make(chan bool),
+	}
+	for i := 0; i < workers; i++ {
+		go l.work()
+	}
+	return l
+}
+
+func (l *chunkLoader) work() {
+	for {
+		select {
+		case f := <-l.queue:
+			chunk, err := l.world.readChunk(f.X, f.Z)
+			l.done(f, chunk, err)
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+func (l *chunkLoader) done(f *ChunkFuture, chunk *Chunk, err os.Error) {
+	if err == nil {
+		xz := MakeXZ(f.X, f.Z)
+		l.world.chunkLock.Lock()
+		// somebody may have put a chunk there while we were reading; theirs is newer.
+		if put, ok := l.world.Chunks[xz]; ok {
+			chunk = put
+		} else {
+			l.world.Chunks[xz] = chunk
+		}
+		l.world.chunkLock.Unlock()
+	}
+	l.lock.Lock()
+	l.pending[MakeXZ(f.X, f.Z)] = nil, false
+	l.lock.Unlock()
+	f.finish(chunk, err)
+}
+
+func (l *chunkLoader) request(x int32, z int32) *ChunkFuture {
+	xz := MakeXZ(x, z)
+	l.lock.Lock()
+	if f, ok := l.pending[xz]; ok {
+		l.lock.Unlock()
+		return f
+	}
+	f := &ChunkFuture{X: x, Z: z, done: make(chan bool)}
+	if l.stopped {
+		l.lock.Unlock()
+		f.finish(nil, error.NewError("world has been closed", nil))
+		return f
+	}
+	if chunk := l.world.Chunk(x, z); chunk != nil {
+		l.lock.Unlock()
+		f.finish(chunk, nil)
+		return f
+	}
+	l.pending[xz] = f
+	l.lock.Unlock()
+
+	// don't hold the lock while we (maybe) wait for room in the queue;
+	// the workers need it to finish what's already in there.
+	select {
+	case l.queue <- f:
+	case <-l.quit:
+		l.done(f, nil, error.NewError("world has been closed", nil))
+		return f
+	}
+
+	// stop may have drained the queue between our check and our send, in which
+	// case nobody would ever read f.  stopped is set before stop drains, so if
+	// it's still clear now, stop hasn't drained yet and will see f.
+	l.lock.Lock()
+	stopped := l.stopped
+	l.lock.Unlock()
+	if stopped {
+		l.drain()
+	}
+	return f
+}
+
+func (l *chunkLoader) stop() {
+	l.lock.Lock()
+	if l.stopped {
+		l.lock.Unlock()
+		return
+	}
+	l.stopped = true
+	close(l.quit)
+	l.lock.Unlock()
+	l.drain()
+}
+
+// Fails everything left in the queue; anyone still waiting on a queued chunk
+// would otherwise wait forever.
+func (l *chunkLoader) drain() {
+	for {
+		select {
+		case f := <-l.queue:
+			l.done(f, nil, error.NewError("world has been closed", nil))
+		default:
+			return
+		}
+	}
+}
+
+// Starts loading the chunk at (x, z) in the background.  Concurrent requests for the
+// same chunk share a single read.  Once the future completes successfully, the chunk
+// is also available in Chunks.
+func (world *World) LoadChunkAsync(x int32, z int32) *ChunkFuture {
+	return world.loader.request(x, z)
+}
+
+// Starts loading every chunk within radius chunks of (x, z), nearest first, so they
+// are already in memory by the time somebody asks for them.  Chunks that fail to
+// load are simply left out; the returned futures report why.
+func (world *World) Prefetch(x int32, z int32, radius int32) []*ChunkFuture {
+	var futures []*ChunkFuture
+	for ring := int32(0); ring <= radius; ring++ {
+		for dx := -ring; dx <= ring; dx++ {
+			for dz := -ring; dz <= ring; dz++ {
+				if abs32(dx) != ring && abs32(dz) != ring {
+					continue // inner rings were already requested
+				}
+				if dx*dx+dz*dz > radius*radius {
+					continue
+				}
+				futures = append(futures, world.LoadChunkAsync(x+dx, z+dz))
+			}
+		}
+	}
+	return futures
+}
+
+func abs32(i int32) int32 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
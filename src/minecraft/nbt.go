@@ -11,6 +11,7 @@ import "fmt"
 import "io"
 import "math"
 import "os"
+import "sort"
 
 type TagType int8
 
@@ -31,6 +32,8 @@ const (
 	String
 	List
 	Compound
+	// added with the Anvil format, for height maps.
+	IntArray
 )
 
 // Load and Save are very common operations that deserve helper functions.
@@ -38,13 +41,18 @@ const (
 // It would be slightly more correct to take an io.Reader, but this is a convenience
 // function anyway.
 func Load(file string) (name string, payload map[string]interface{}, err os.Error) {
-	gz, err := os.Open(file, os.O_RDONLY, 0000)
+	f, err := os.Open(file, os.O_RDONLY, 0000)
 	if err != nil {
 		err = error.NewError("could not open file", err)
 		return
 	}
-	defer gz.Close()
-	nbtf, err := gzip.NewReader(gz)
+	defer f.Close()
+	return ReadGzipped(f)
+}
+
+// Reads a gzipped compound tag, the way nbt files are stored.
+func ReadGzipped(reader io.Reader) (name string, payload map[string]interface{}, err os.Error) {
+	nbtf, err := gzip.NewReader(reader)
 	if err != nil {
 		err = error.NewError("could not gunzip file", err)
 		return
@@ -57,10 +65,54 @@ func Load(file string) (name string, payload map[string]interface{}, err os.Erro
 	}
 	return
 }
+
 // It would be slightly more correct to take an io.Writer, but this is a convenience
 // function anyway.
+//
+// The file is written alongside the original and renamed over it once complete, so
+// a crash part way through never leaves a half-written file behind.
 func Save(file string, name string, payload map[string]interface{}) (err os.Error) {
-	panic("writeme")
+	tmp := file + ".tmp"
+	f, err := os.Open(tmp, os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		err = error.NewError("could not create file", err)
+		return
+	}
+	if err = WriteGzipped(f, name, payload); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		err = error.NewError("could not close file", err)
+		return
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		err = error.NewError("could not replace file", err)
+		return
+	}
+	return
+}
+
+// Writes a gzipped compound tag, the way nbt files are stored.
+func WriteGzipped(writer io.Writer, name string, payload map[string]interface{}) (err os.Error) {
+	gz, err := gzip.NewWriter(writer)
+	if err != nil {
+		err = error.NewError("could not gzip file", err)
+		return
+	}
+	if err = WriteTagCompound(gz, name, payload); err != nil {
+		gz.Close()
+		err = error.NewError("could not write compound tag", err)
+		return
+	}
+	if err = gz.Close(); err != nil {
+		err = error.NewError("could not finish gzipping file", err)
+		return
+	}
+	return
 }
 
 // Named tag readers.
@@ -83,7 +135,92 @@ func ReadNamedTag(reader io.Reader) (t NamedTag, err os.Error) {
 }
 
 func WriteNamedTag(writer io.Writer, t NamedTag) (err os.Error) {
-	panic("writeme")
+	if err = WriteInt8(writer, int8(t.Type)); err != nil {
+		err = error.NewError("could not write tag type", err)
+		return
+	}
+	if t.Type == End {
+		return
+	}
+	if err = WriteString(writer, t.Name); err != nil {
+		err = error.NewError("could not write tag name", err)
+		return
+	}
+	return
+}
+
+func WriteTagCompound(writer io.Writer, name string, payload map[string]interface{}) (err os.Error) {
+	if err = WriteNamedTag(writer, NamedTag{Compound, name}); err != nil {
+		err = error.NewError("could not write named tag", err)
+		return
+	}
+	if err = WriteCompound(writer, payload); err != nil {
+		err = error.NewError("could not write compound tag", err)
+		return
+	}
+	return
+}
+
+// Returns the tag type that payload would be written as.  These are the same
+// types readPayload produces.
+func TypeOf(payload interface{}) (ttype TagType, err os.Error) {
+	switch payload.(type) {
+	case int8:
+		ttype = Byte
+	case int16:
+		ttype = Short
+	case int32:
+		ttype = Int
+	case int64:
+		ttype = Long
+	case float32:
+		ttype = Float
+	case float64:
+		ttype = Double
+	case []byte:
+		ttype = ByteArray
+	case string:
+		ttype = String
+	case []interface{}:
+		ttype = List
+	case map[string]interface{}:
+		ttype = Compound
+	case []int32:
+		ttype = IntArray
+	default:
+		err = (os.ErrorString)(fmt.Sprintf("nbt.TypeOf: no tag type for %T", payload))
+	}
+	return
+}
+
+func writePayload(writer io.Writer, payload interface{}) (err os.Error) {
+	switch p := payload.(type) {
+	case int8:
+		err = WriteInt8(writer, p)
+	case int16:
+		err = WriteInt16(writer, p)
+	case int32:
+		err = WriteInt32(writer, p)
+	case int64:
+		err = WriteInt64(writer, p)
+	case float32:
+		err = WriteFloat32(writer, p)
+	case float64:
+		err = WriteFloat64(writer, p)
+	case []byte:
+		err = WriteByteArray(writer, p)
+	case string:
+		err = WriteString(writer, p)
+	case []interface{}:
+		err = WriteList(writer, p)
+	case map[string]interface{}:
+		err = WriteCompound(writer, p)
+	case []int32:
+		err = WriteIntArray(writer, p)
+	default:
+		err = (os.ErrorString)(fmt.Sprintf("nbt.writePayload: can't write a %T", payload))
+	}
+	return
 }
 
 
@@ -159,6 +296,11 @@ func readPayload(reader io.Reader, ttype TagType) (payload interface{}, err os.E
 		if err != nil {
 			err = error.NewError("could not read payload compound", err)
 		}
+	case IntArray:
+		payload, err = ReadIntArray(reader)
+		if err != nil {
+			err = error.NewError("could not read payload int array", err)
+		}
 	default:
 		err = (os.ErrorString)(fmt.Sprint("nbt.readPayload: unknown payload type ", ttype))
 	}
@@ -203,6 +345,7 @@ func ReadByteArray(reader io.Reader) (b []byte, err os.Error) {
 	}
 	if length < 0 {
 		err = error.NewError("byte array's length cannot be < 0", nil)
+		return
 	}
 	b = make([]byte, length)
 	if _, err = io.ReadFull(reader, b); err != nil {
@@ -246,6 +389,35 @@ func ReadCompound(reader io.Reader) (c map[string]interface{}, err os.Error) {
 	panic("shouldn't get here")
 }
 
+func WriteCompound(writer io.Writer, c map[string]interface{}) (err os.Error) {
+	// write in a stable order, so saving the same thing twice gives the same bytes.
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.SortStrings(names)
+	for _, name := range names {
+		var ttype TagType
+		if ttype, err = TypeOf(c[name]); err != nil {
+			err = error.NewError(fmt.Sprint("could not write tag ", name), err)
+			return
+		}
+		if err = WriteNamedTag(writer, NamedTag{ttype, name}); err != nil {
+			err = error.NewError("could not write named tag", err)
+			return
+		}
+		if err = writePayload(writer, c[name]); err != nil {
+			err = error.NewError(fmt.Sprint("could not write payload of ", name), err)
+			return
+		}
+	}
+	if err = WriteNamedTag(writer, NamedTag{Type: End}); err != nil {
+		err = error.NewError("could not write end tag", err)
+		return
+	}
+	return
+}
+
 func ReadFloat32(reader io.Reader) (f float32, err os.Error) {
 	var i32 int32
 	if i32, err = ReadInt32(reader); err != nil {
@@ -378,6 +550,44 @@ func WriteInt64(writer io.Writer, i int64) (err os.Error) {
 	return
 }
 
+func ReadIntArray(reader io.Reader) (a []int32, err os.Error) {
+	var length int32
+	if length, err = ReadInt32(reader); err != nil {
+		err = error.NewError("could not read int array's length", err)
+		return
+	}
+	if length < 0 {
+		err = error.NewError("int array's length cannot be < 0", nil)
+		return
+	}
+	b := make([]byte, 4*int64(length))
+	if _, err = io.ReadFull(reader, b); err != nil {
+		err = error.NewError("could not read int array", err)
+		return
+	}
+	a = make([]int32, length)
+	for i := range a {
+		a[i] = int32(uint32(b[4*i+3]) | uint32(b[4*i+2])<<8 | uint32(b[4*i+1])<<16 | uint32(b[4*i])<<24)
+	}
+	return
+}
+
+func WriteIntArray(writer io.Writer, a []int32) (err os.Error) {
+	if len(a) > math.MaxInt32 {
+		return (os.ErrorString)("nbt.WriteIntArray: int array was too long")
+	}
+	if err = WriteInt32(writer, int32(len(a))); err != nil {
+		return
+	}
+	b := make([]byte, 4*len(a))
+	for i, v := range a {
+		u := uint32(v)
+		b[4*i], b[4*i+1], b[4*i+2], b[4*i+3] = byte(u>>24), byte(u>>16), byte(u>>8), byte(u)
+	}
+	_, err = writer.Write(b)
+	return
+}
+
 func ReadList(reader io.Reader) (l []interface{}, err os.Error) {
 	var ttypei8 int8
 	var llen int32
@@ -408,6 +618,39 @@ func ReadList(reader io.Reader) (l []interface{}, err os.Error) {
 	return
 }
 
+// Lists don't remember their element type once read, so it is taken from the first
+// element.  Empty lists are written as lists of bytes, like Minecraft does.
+func WriteList(writer io.Writer, l []interface{}) (err os.Error) {
+	ttype := Byte
+	if len(l) > 0 {
+		if ttype, err = TypeOf(l[0]); err != nil {
+			err = error.NewError("could not determine list type", err)
+			return
+		}
+	}
+	if len(l) > math.MaxInt32 {
+		return (os.ErrorString)("nbt.WriteList: list was too long")
+	}
+	if err = WriteInt8(writer, int8(ttype)); err != nil {
+		err = error.NewError("could not write list type", err)
+		return
+	}
+	if err = WriteInt32(writer, int32(len(l))); err != nil {
+		err = error.NewError("could not write list length", err)
+		return
+	}
+	for i, payload := range l {
+		if t, _ := TypeOf(payload); t != ttype {
+			return error.NewError(fmt.Sprint("list element ", i, " doesn't match the list's type"), nil)
+		}
+		if err = writePayload(writer, payload); err != nil {
+			err = error.NewError(fmt.Sprint("could not write list payload at index ", i), err)
+			return
+		}
+	}
+	return
+}
+
 func ReadString(reader io.Reader) (s string, err os.Error) {
 	var strlen int16
 
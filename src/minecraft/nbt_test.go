@@ -3,6 +3,9 @@ package nbt
 import "testing"
 import "bytes"
 import "compress/gzip"
+import "io/ioutil"
+import "os"
+import "path"
 import "reflect"
 
 func TestTestNbt(t *testing.T) {
@@ -53,6 +56,61 @@ func xTestBigTestNbt(t *testing.T) {
 	})
 }
 
+func TestWriteRoundTrip(t *testing.T) {
+	payload := map[string]interface{}{
+		"byte":   int8(-3),
+		"short":  int16(300),
+		"int":    int32(-70000),
+		"long":   int64(1) << 40,
+		"float":  float32(0.5),
+		"double": float64(-0.25),
+		"bytes":  []byte{1, 2, 3},
+		"ints":   []int32{-1, 0, 1 << 20},
+		"string": "hello",
+		"list":   []interface{}{float64(1), float64(2)},
+		"empty":  []interface{}{},
+		"compound": map[string]interface{}{
+			"name": "Bananrama",
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteTagCompound(&buf, "root", payload); err != nil {
+		t.Fatal(err)
+	}
+	name, read, err := ReadTagCompound(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "root" {
+		t.Error("expected root, got ", name)
+	}
+	if !reflect.DeepEqual(read, payload) {
+		t.Error("expected ", payload, ", got ", read)
+	}
+}
+
+func TestWriteMixedList(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteList(&buf, []interface{}{int8(1), "two"}); err == nil {
+		t.Error("expected an error writing a list with mixed element types")
+	}
+}
+
+func TestSaveFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "test.dat")
+	if err = Save(file, "", map[string]interface{}{"list": []interface{}{int8(1), "two"}}); err == nil {
+		t.Error("expected an error saving a list with mixed element types")
+	}
+	if _, err = os.Stat(file + ".tmp"); err == nil {
+		t.Error("expected the temporary file to be removed")
+	}
+}
+
 func testGZippedFile(t *testing.T, nbtb []byte, expectedName string, expectedPayload map[string]interface{}) {
 	gzbuf := bytes.NewBuffer(nbtb)
 	buf, err := gzip.NewReader(gzbuf)
@@ -0,0 +1,92 @@
+package world
+
+import "math"
+import "rand"
+
+// Ken Perlin's improved noise, with the permutation table shuffled from a seed
+// so that every world gets its own terrain.
+// see: http://mrl.nyu.edu/~perlin/noise/
+type perlin struct {
+	perm [512]int
+}
+
+func newPerlin(r *rand.Rand) *perlin {
+	p := new(perlin)
+	for i := 0; i < 256; i++ {
+		p.perm[i] = i
+	}
+	for i := 255; i > 0; i-- {
+		j := r.Intn(i + 1)
+		p.perm[i], p.perm[j] = p.perm[j], p.perm[i]
+	}
+	for i := 0; i < 256; i++ {
+		p.perm[i+256] = p.perm[i]
+	}
+	return p
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t float64, a float64, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x float64, y float64, z float64) float64 {
+	h := hash & 15
+	u, v := y, z
+	if h < 8 {
+		u = x
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// Returns noise in roughly [-1, 1].
+func (p *perlin) noise3(x float64, y float64, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	A := p.perm[X] + Y
+	AA, AB := p.perm[A]+Z, p.perm[A+1]+Z
+	B := p.perm[X+1] + Y
+	BA, BB := p.perm[B]+Z, p.perm[B+1]+Z
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p.perm[AA], x, y, z), grad(p.perm[BA], x-1, y, z)),
+			lerp(u, grad(p.perm[AB], x, y-1, z), grad(p.perm[BB], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p.perm[AA+1], x, y, z-1), grad(p.perm[BA+1], x-1, y, z-1)),
+			lerp(u, grad(p.perm[AB+1], x, y-1, z-1), grad(p.perm[BB+1], x-1, y-1, z-1))))
+}
+
+func (p *perlin) noise2(x float64, z float64) float64 {
+	return p.noise3(x, 0, z)
+}
+
+// Fractal noise: several octaves of noise2, each at twice the frequency and
+// half the amplitude of the last.
+func (p *perlin) octaves2(x float64, z float64, octaves int) float64 {
+	var sum, amp, norm float64 = 0, 1, 0
+	for i := 0; i < octaves; i++ {
+		sum += p.noise2(x, z) * amp
+		norm += amp
+		amp /= 2
+		x, z = x*2, z*2
+	}
+	return sum / norm
+}
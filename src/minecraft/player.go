@@ -0,0 +1,220 @@
+package world
+
+import "minecraft/error"
+import "minecraft/registry"
+
+import "fmt"
+import "os"
+
+// A player's saved state.  Single-player worlds embed it in level.dat; multiplayer
+// worlds keep one per player in players/<name>.dat.
+// see: http://www.minecraftwiki.net/wiki/Alpha_Level_Format#level.dat_Format
+type Player struct {
+	Physics      Physics
+	FallDistance float32
+	Fire         int16
+	Air          int16
+	OnGround     int8
+	AttackTime   int16
+	DeathTime    int16
+	HurtTime     int16
+	Health       int16
+	Dimension    int32
+	Score        int32
+	Inventory    []InventorySlot
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
+}
+
+type InventorySlot struct {
+	Slot int8
+	Item Item
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
+}
+
+func toPhysics(r *compoundReader) Physics {
+	xyz := r.float64s("Pos", 3)
+	dxdydz := r.float64s("Motion", 3)
+	rot := r.float32s("Rotation", 2)
+	return Physics{
+		Position{xyz[0], xyz[1], xyz[2]},
+		Velocity{dxdydz[0], dxdydz[1], dxdydz[2]},
+		Euler{Yaw: rot[0], Pitch: rot[1]},
+	}
+}
+
+func (p *Physics) toNBT(c map[string]interface{}) {
+	c["Pos"] = doubleList(p.Position.X, p.Position.Y, p.Position.Z)
+	c["Motion"] = doubleList(p.Velocity.DX, p.Velocity.DY, p.Velocity.DZ)
+	c["Rotation"] = floatList(p.Euler.Yaw, p.Euler.Pitch)
+}
+
+// Stacks bigger than the item allows are cut down to size; items we don't know are
+// left alone.
+func toItem(r *compoundReader) Item {
+	item := Item{
+		Id:     r.int16("id"),
+		Count:  r.int8("Count"),
+		Damage: r.int16("Damage"),
+	}
+	if known := registry.GetItem(item.Id); known != nil && item.Count > known.MaxStack {
+		item.Count = known.MaxStack
+	}
+	return item
+}
+
+func (item *Item) toNBT(c map[string]interface{}) {
+	c["id"] = item.Id
+	c["Count"] = item.Count
+	c["Damage"] = item.Damage
+}
+
+func toInventory(r *compoundReader, key string) (inv []InventorySlot) {
+	for _, v := range r.list(key) {
+		c, ok := v.(map[string]interface{})
+		if !ok {
+			r.wrongType(key, "a list of compounds", v)
+			return
+		}
+		ir := newCompoundReader(c)
+		slot := InventorySlot{Slot: ir.int8("Slot"), Item: toItem(ir)}
+		slot.Extra = ir.extra()
+		if ir.err != nil && r.err == nil {
+			r.err = ir.err
+		}
+		inv = append(inv, slot)
+	}
+	return
+}
+
+func inventoryToNBT(inv []InventorySlot) []interface{} {
+	l := make([]interface{}, len(inv))
+	for i, slot := range inv {
+		c := newCompound(slot.Extra)
+		c["Slot"] = slot.Slot
+		slot.Item.toNBT(c)
+		l[i] = c
+	}
+	return l
+}
+
+func toPlayer(c map[string]interface{}) (p *Player, err os.Error) {
+	r := newCompoundReader(c)
+	p = &Player{
+		Physics:      toPhysics(r),
+		FallDistance: r.float32("FallDistance"),
+		Fire:         r.int16("Fire"),
+		Air:          r.int16("Air"),
+		OnGround:     r.int8("OnGround"),
+		AttackTime:   r.int16("AttackTime"),
+		DeathTime:    r.int16("DeathTime"),
+		HurtTime:     r.int16("HurtTime"),
+		Health:       r.int16("Health"),
+		Dimension:    r.int32("Dimension"),
+		Score:        r.int32("Score"),
+		Inventory:    toInventory(r, "Inventory"),
+	}
+	p.Extra = r.extra()
+	err = r.err
+	return
+}
+
+func (p *Player) toNBT() map[string]interface{} {
+	c := newCompound(p.Extra)
+	p.Physics.toNBT(c)
+	c["FallDistance"] = p.FallDistance
+	c["Fire"] = p.Fire
+	c["Air"] = p.Air
+	c["OnGround"] = p.OnGround
+	c["AttackTime"] = p.AttackTime
+	c["DeathTime"] = p.DeathTime
+	c["HurtTime"] = p.HurtTime
+	c["Health"] = p.Health
+	c["Dimension"] = p.Dimension
+	c["Score"] = p.Score
+	c["Inventory"] = inventoryToNBT(p.Inventory)
+	return c
+}
+
+const playersdir = "players"
+
+// Player names end up in file names, so only allow what Minecraft does.
+func validPlayerName(name string) bool {
+	if len(name) == 0 || len(name) > 16 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func checkPlayerName(name string) os.Error {
+	if !validPlayerName(name) {
+		return error.NewError(fmt.Sprintf("invalid player name %q", name), nil)
+	}
+	return nil
+}
+
+// Loads the saved state of a multiplayer player.  Players who have never been
+// saved get a nil Player and no error; NewPlayer makes one for them.
+func (world *World) LoadPlayer(name string) (p *Player, err os.Error) {
+	if err = checkPlayerName(name); err != nil {
+		return
+	}
+	b, err := world.storage.Player(name)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not read player ", name), err)
+		return
+	}
+	if b == nil {
+		return
+	}
+	_, payload, err := decodeNBT(b)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not read player ", name), err)
+		return
+	}
+	if p, err = toPlayer(payload); err != nil {
+		err = error.NewError(fmt.Sprint("could not understand player ", name), err)
+		return
+	}
+	return
+}
+
+// Makes a player who has never been in the world before, standing at a safe spot
+// near the world's spawn.
+func (world *World) NewPlayer() (p *Player, err os.Error) {
+	spawn, err := world.SafeSpawn()
+	if err != nil {
+		err = error.NewError("could not find somewhere for a new player", err)
+		return
+	}
+	p = &Player{Air: 300, Health: 20, OnGround: 1}
+	p.Physics.Position = Position{float64(spawn.X) + 0.5, float64(spawn.Y) + PlayerEyeHeight, float64(spawn.Z) + 0.5}
+	return
+}
+
+// Saves the state of a multiplayer player, replacing whatever was saved before.
+func (world *World) SavePlayer(name string, p *Player) (err os.Error) {
+	if err = checkPlayerName(name); err != nil {
+		return
+	}
+	if err = world.verifyWritable(); err != nil {
+		return
+	}
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	b, err := encodeNBT("", p.toNBT())
+	if err == nil {
+		err = world.storage.PutPlayer(name, b)
+	}
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not save player ", name), err)
+		return
+	}
+	return
+}
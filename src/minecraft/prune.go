@@ -0,0 +1,161 @@
+package world
+
+import "bytes"
+import "os"
+import "reflect"
+
+// Every chunk within Radius chunks of chunk (X, Z): the same circle Prefetch loads.
+type KeepArea struct {
+	X, Z   int32
+	Radius int32
+}
+
+func (a KeepArea) contains(x int32, z int32) bool {
+	dx, dz := int64(x-a.X), int64(z-a.Z)
+	return dx*dx+dz*dz <= int64(a.Radius)*int64(a.Radius)
+}
+
+type PruneOptions struct {
+	// If not empty, chunks outside all of these areas are removed.
+	Keep []KeepArea
+	// Remove chunks, wherever they are, that are just as the generator would make
+	// them: the same blocks and tile entities, and no entities but wild mobs.
+	// Light isn't compared, since it depends on what the neighbours were like.
+	Unedited bool
+	// Work out what would be removed, and how much space it would free, without
+	// removing anything.  Works on a world opened read-only.
+	DryRun bool
+	// What unedited chunks are compared with.  nil means the world's Generator, or
+	// the default generator for the world's seed if it has none.
+	Generator Generator
+}
+
+type PruneReport struct {
+	// chunk files looked at.
+	Chunks int
+	// the chunks removed, or that would have been.
+	Removed []XZ
+	// bytes of chunk files removed.
+	Freed int64
+}
+
+// Removes chunks explored once and never touched again, so they are generated
+// afresh if anyone goes back.  Chunks with unsaved changes are never removed.
+// Unless it is a dry run, Data.SizeOnDisk goes down by the space freed and
+// level.dat is saved.  Best done while nobody is playing: a chunk changed while
+// Prune is looking at it may be removed anyway.  If a chunk can't be read or
+// removed, Prune stops and returns what it removed so far along with the error.
+func (world *World) Prune(options *PruneOptions) (report *PruneReport, err os.Error) {
+	var opts PruneOptions
+	if options != nil {
+		opts = *options
+	}
+	if !opts.DryRun {
+		if err = world.verifyWritable(); err != nil {
+			return
+		}
+	}
+	g := opts.Generator
+	if g == nil {
+		g = world.Generator
+	}
+	if g == nil {
+		g = NewDefaultGenerator(world.Data.RandomSeed)
+	}
+	report = &PruneReport{}
+	for _, xz := range world.ChunkCoords() {
+		x, z := xz.X(), xz.Z()
+		report.Chunks++
+		if world.hasUnsavedChanges(x, z) {
+			continue
+		}
+		remove := len(opts.Keep) > 0 && !inKeepAreas(opts.Keep, x, z)
+		if !remove && opts.Unedited {
+			if remove, err = world.unedited(g, x, z); err != nil {
+				break
+			}
+		}
+		if !remove {
+			continue
+		}
+		var size int64
+		if size, err = world.ChunkSize(x, z); err != nil {
+			break
+		}
+		if !opts.DryRun {
+			if err = world.RemoveChunk(x, z); err != nil {
+				break
+			}
+		}
+		report.Removed = append(report.Removed, xz)
+		report.Freed += size
+	}
+	if opts.DryRun || report.Freed == 0 {
+		return
+	}
+
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	world.Data.SizeOnDisk -= report.Freed
+	if world.Data.SizeOnDisk < 0 {
+		world.Data.SizeOnDisk = 0
+	}
+	if e := world.saveLevel(); err == nil {
+		err = e
+	}
+	return
+}
+
+func inKeepAreas(areas []KeepArea, x int32, z int32) bool {
+	for _, a := range areas {
+		if a.contains(x, z) {
+			return true
+		}
+	}
+	return false
+}
+
+func (world *World) hasUnsavedChanges(x int32, z int32) bool {
+	world.chunkLock.Lock()
+	defer world.chunkLock.Unlock()
+	chunk, ok := world.Chunks[MakeXZ(x, z)]
+	return ok && chunk.dirty
+}
+
+// Whether the chunk at (x, z), as saved, is what g makes there.
+func (world *World) unedited(g Generator, x int32, z int32) (bool, os.Error) {
+	saved, err := world.readChunk(x, z)
+	if err != nil {
+		return false, err
+	}
+	generated, err := g.Generate(x, z)
+	if err != nil {
+		return false, err
+	}
+	a, b := &saved.Level, &generated.Level
+	if !bytes.Equal(a.Blocks, b.Blocks) || !bytes.Equal(a.Data, b.Data) {
+		return false, nil
+	}
+	// a chest that has been emptied counts as a change.
+	if !reflect.DeepEqual(tileEntityListToNBT(a.TileEntities), tileEntityListToNBT(b.TileEntities)) {
+		return false, nil
+	}
+	for _, e := range a.Entities {
+		if !isWild(e) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Mobs come and go by themselves; everything else that moves, from dropped items to
+// minecarts, was put there by a player.
+func isWild(e Entity) bool {
+	switch m := e.(type) {
+	case *Pig:
+		return m.Saddle == 0
+	case *SimpleMob, *Sheep, *Slime, *PigZombie:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package world
+
+import "minecraft/registry"
+
+import "os"
+import "testing"
+
+func chunkCount(w *World) int {
+	return len(w.ChunkCoords())
+}
+
+func TestPrune(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 3, Pregenerate: true, PregenerateRadius: 2})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+	if n := chunkCount(w); n != 13 {
+		t.Fatal("expected 13 pregenerated chunks, got ", n)
+	}
+
+	// a dry run finds the 8 chunks outside radius 1, but leaves them be.
+	report, err := w.Prune(&PruneOptions{Keep: []KeepArea{{0, 0, 1}}, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Chunks != 13 || len(report.Removed) != 8 || report.Freed <= 0 {
+		t.Errorf("expected 8 of 13 chunks to go, got %d of %d freeing %d bytes", len(report.Removed), report.Chunks, report.Freed)
+	}
+	if n := chunkCount(w); n != 13 {
+		t.Error("a dry run removed chunks, leaving ", n)
+	}
+
+	// build in (1, 0), saddle a pig in (0, -1) and let a cow wander into (-1, 0).
+	if err = w.SetBlock(16, 120, 0, registry.Glass, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		x, z int32
+		e    Entity
+	}{{0, -1, &Pig{Saddle: 1}}, {-1, 0, &SimpleMob{id: "Cow"}}} {
+		if err = w.LoadChunk(c.x, c.z); err != nil {
+			t.Fatal(err)
+		}
+		chunk := w.Chunk(c.x, c.z)
+		chunk.Level.Entities = append(chunk.Level.Entities, c.e)
+		chunk.dirty = true
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Data.SizeOnDisk = 1 << 20
+	if report, err = w.Prune(&PruneOptions{Unedited: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 11 {
+		t.Error("expected every chunk but the edited two to be removed, removed ", len(report.Removed))
+	}
+	for _, xz := range report.Removed {
+		if xz == MakeXZ(1, 0) || xz == MakeXZ(0, -1) {
+			t.Errorf("edited chunk (%d, %d) was removed", xz.X(), xz.Z())
+		}
+	}
+	if n := chunkCount(w); n != 2 {
+		t.Error("expected 2 chunks left, got ", n)
+	}
+	if w.Data.SizeOnDisk != 1<<20-report.Freed {
+		t.Errorf("expected SizeOnDisk to go down by %d, got %d", report.Freed, w.Data.SizeOnDisk)
+	}
+
+	// pruned chunks come back as they were.
+	if id, _, err := w.Block(0, 0, 0); err != nil || id != registry.Bedrock {
+		t.Errorf("expected a regenerated chunk with bedrock, got %d (%v)", id, err)
+	}
+}
@@ -0,0 +1,258 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "bytes"
+import "compress/gzip"
+import "compress/zlib"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "os"
+import "path"
+import "strconv"
+import "strings"
+import "time"
+
+// McRegion and Anvil worlds keep their chunks in region files, 32 by 32 chunks to
+// a file, in this directory.
+// see: http://www.minecraftwiki.net/wiki/Region_file_format
+const regiondir = "region"
+
+const (
+	regionSize = 32
+	sectorSize = 4096
+	// the header is a sector of chunk locations, then a sector of timestamps.
+	headerSectors = 2
+	// a location's sector count is a single byte.
+	maxChunkSectors = 255
+
+	// how a chunk in a region file is compressed.
+	compressGzip = 1
+	compressZlib = 2
+)
+
+func regionPath(dir string, x int32, z int32, ext string) string {
+	return path.Join(dir, regiondir, fmt.Sprintf("r.%d.%d.%s", x>>5, z>>5, ext))
+}
+
+// Where the chunk at (x, z) goes in its region's header.
+func regionIndex(x int32, z int32) int {
+	return int(x&(regionSize-1)) + int(z&(regionSize-1))*regionSize
+}
+
+// Parses r.<x>.<z>.<ext>, giving the region's coordinates.
+func parseRegionName(name string, ext string) (x int32, z int32, ok bool) {
+	if !strings.HasPrefix(name, "r.") || !strings.HasSuffix(name, "."+ext) {
+		return
+	}
+	xz := strings.Split(name[2:len(name)-len(ext)-1], ".", -1)
+	if len(xz) != 2 {
+		return
+	}
+	rx, e1 := strconv.Atoi(xz[0])
+	rz, e2 := strconv.Atoi(xz[1])
+	if e1 != nil || e2 != nil {
+		return
+	}
+	return int32(rx), int32(rz), true
+}
+
+// Returns the coordinates of every chunk in the region files with extension ext
+// under dir, a region at a time.  Region files that can't be read are skipped.
+func regionChunkCoords(dir string, ext string) (coords []XZ) {
+	files, err := ioutil.ReadDir(path.Join(dir, regiondir))
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		rx, rz, ok := parseRegionName(f.Name, ext)
+		if !ok || !f.IsRegular() {
+			continue
+		}
+		r, err := openRegion(path.Join(dir, regiondir, f.Name))
+		if err != nil {
+			continue
+		}
+		for i, loc := range r.locations {
+			if loc != 0 {
+				coords = append(coords, MakeXZ(rx*regionSize+int32(i%regionSize), rz*regionSize+int32(i/regionSize)))
+			}
+		}
+		r.Close()
+	}
+	return
+}
+
+// A region file opened for reading.
+type regionReader struct {
+	f *os.File
+	// for each chunk, its first sector<<8 | how many sectors it takes, or 0 if
+	// it isn't there.
+	locations [regionSize * regionSize]uint32
+}
+
+func openRegion(file string) (r *regionReader, err os.Error) {
+	f, err := os.Open(file, os.O_RDONLY, 0000)
+	if err != nil {
+		err = error.NewError("could not open region file", err)
+		return
+	}
+	r = &regionReader{f: f}
+	for i := range r.locations {
+		var loc int32
+		if loc, err = nbt.ReadInt32(f); err != nil {
+			f.Close()
+			r = nil
+			err = error.NewError("could not read region header", err)
+			return
+		}
+		r.locations[i] = uint32(loc)
+	}
+	return
+}
+
+func (r *regionReader) Close() os.Error {
+	return r.f.Close()
+}
+
+// Reads the chunk at (x, z), which must be in this region.
+func (r *regionReader) read(x int32, z int32) (payload map[string]interface{}, err os.Error) {
+	loc := r.locations[regionIndex(x, z)]
+	if loc == 0 {
+		err = error.NewError(fmt.Sprintf("region has no chunk (%d, %d)", x, z), nil)
+		return
+	}
+	sectors := make([]byte, (loc&0xff)*sectorSize)
+	if _, err = r.f.ReadAt(sectors, int64(loc>>8)*sectorSize); err != nil {
+		err = error.NewError(fmt.Sprintf("could not read chunk (%d, %d)", x, z), err)
+		return
+	}
+	buf := bytes.NewBuffer(sectors)
+	length, _ := nbt.ReadInt32(buf)
+	if length < 1 || int(length) > buf.Len() {
+		err = error.NewError(fmt.Sprintf("chunk (%d, %d) has a bad length", x, z), nil)
+		return
+	}
+	compression, _ := buf.ReadByte()
+	data := bytes.NewBuffer(buf.Bytes()[:length-1])
+	var rc io.ReadCloser
+	switch compression {
+	case compressGzip:
+		rc, err = gzip.NewReader(data)
+	case compressZlib:
+		rc, err = zlib.NewReader(data)
+	default:
+		err = error.NewError(fmt.Sprint("unknown compression ", compression), nil)
+	}
+	if err != nil {
+		err = error.NewError(fmt.Sprintf("could not decompress chunk (%d, %d)", x, z), err)
+		return
+	}
+	defer rc.Close()
+	if _, payload, err = nbt.ReadTagCompound(rc); err != nil {
+		err = error.NewError(fmt.Sprintf("could not read chunk (%d, %d)", x, z), err)
+		return
+	}
+	return
+}
+
+// A region file being written from scratch, one chunk after another.  Nothing
+// appears under the file's real name until Close.
+type regionWriter struct {
+	f          *os.File
+	file       string
+	locations  [regionSize * regionSize]uint32
+	timestamps [regionSize * regionSize]uint32
+	// the next free sector.
+	next uint32
+}
+
+func createRegion(file string) (w *regionWriter, err os.Error) {
+	f, err := os.Open(file+".tmp", os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		err = error.NewError("could not create region file", err)
+		return
+	}
+	w = &regionWriter{f: f, file: file, next: headerSectors}
+	// the header is filled in by Close.
+	if _, err = f.Write(make([]byte, headerSectors*sectorSize)); err != nil {
+		f.Close()
+		w = nil
+		err = error.NewError("could not write region header", err)
+		return
+	}
+	return
+}
+
+// Appends the chunk at (x, z), which must be in this region, zlib compressed as
+// Minecraft writes them.
+func (w *regionWriter) write(x int32, z int32, payload map[string]interface{}) (err os.Error) {
+	var data bytes.Buffer
+	zw, err := zlib.NewWriter(&data)
+	if err != nil {
+		return
+	}
+	if err = nbt.WriteTagCompound(zw, "", payload); err != nil {
+		zw.Close()
+		err = error.NewError(fmt.Sprintf("could not write chunk (%d, %d)", x, z), err)
+		return
+	}
+	if err = zw.Close(); err != nil {
+		return
+	}
+	// the length counts the compression byte, but not itself.
+	length := data.Len() + 1
+	sectors := (4 + length + sectorSize - 1) / sectorSize
+	if sectors > maxChunkSectors {
+		return error.NewError(fmt.Sprintf("chunk (%d, %d) is too big for a region file", x, z), nil)
+	}
+	var out bytes.Buffer
+	nbt.WriteInt32(&out, int32(length))
+	out.WriteByte(compressZlib)
+	out.Write(data.Bytes())
+	out.Write(make([]byte, sectors*sectorSize-out.Len()))
+	if _, err = w.f.Write(out.Bytes()); err != nil {
+		err = error.NewError(fmt.Sprintf("could not write chunk (%d, %d)", x, z), err)
+		return
+	}
+	i := regionIndex(x, z)
+	w.locations[i] = w.next<<8 | uint32(sectors)
+	w.timestamps[i] = uint32(time.Seconds())
+	w.next += uint32(sectors)
+	return
+}
+
+// Writes the header and puts the file in place.
+func (w *regionWriter) Close() (err os.Error) {
+	var header bytes.Buffer
+	for _, loc := range w.locations {
+		nbt.WriteInt32(&header, int32(loc))
+	}
+	for _, ts := range w.timestamps {
+		nbt.WriteInt32(&header, int32(ts))
+	}
+	if _, err = w.f.Seek(0, 0); err == nil {
+		_, err = w.f.Write(header.Bytes())
+	}
+	if err != nil {
+		w.abandon()
+		return error.NewError("could not write region header", err)
+	}
+	if err = w.f.Close(); err != nil {
+		os.Remove(w.file + ".tmp")
+		return error.NewError("could not close region file", err)
+	}
+	if err = os.Rename(w.file+".tmp", w.file); err != nil {
+		os.Remove(w.file + ".tmp")
+		return error.NewError("could not replace region file", err)
+	}
+	return
+}
+
+// Throws away everything written so far, leaving the real file as it was.
+func (w *regionWriter) abandon() {
+	w.f.Close()
+	os.Remove(w.file + ".tmp")
+}
@@ -0,0 +1,457 @@
+// What every Alpha block and item is, so code can ask about "stone" or "torch"
+// rather than hardcoding numbers.
+// see: http://www.minecraftwiki.net/wiki/Data_values
+
+package registry
+
+import "fmt"
+
+// Block ids.  Every block is also an item, with the same id.
+const (
+	Air                 = 0
+	Stone               = 1
+	Grass               = 2
+	Dirt                = 3
+	Cobblestone         = 4
+	Planks              = 5
+	Sapling             = 6
+	Bedrock             = 7
+	Water               = 8
+	StillWater          = 9
+	Lava                = 10
+	StillLava           = 11
+	Sand                = 12
+	Gravel              = 13
+	GoldOre             = 14
+	IronOre             = 15
+	CoalOre             = 16
+	Log                 = 17
+	Leaves              = 18
+	Sponge              = 19
+	Glass               = 20
+	Wool                = 35
+	Dandelion           = 37
+	Rose                = 38
+	BrownMushroom       = 39
+	RedMushroom         = 40
+	GoldBlock           = 41
+	IronBlock           = 42
+	DoubleSlab          = 43
+	Slab                = 44
+	Brick               = 45
+	TNT                 = 46
+	Bookshelf           = 47
+	MossyCobblestone    = 48
+	Obsidian            = 49
+	Torch               = 50
+	Fire                = 51
+	MobSpawner          = 52
+	WoodenStairs        = 53
+	Chest               = 54
+	RedstoneWire        = 55
+	DiamondOre          = 56
+	DiamondBlock        = 57
+	Workbench           = 58
+	Crops               = 59
+	Farmland            = 60
+	Furnace             = 61
+	BurningFurnace      = 62
+	SignPost            = 63
+	WoodenDoor          = 64
+	Ladder              = 65
+	Rails               = 66
+	CobblestoneStairs   = 67
+	WallSign            = 68
+	Lever               = 69
+	StonePressurePlate  = 70
+	IronDoor            = 71
+	WoodenPressurePlate = 72
+	RedstoneOre         = 73
+	GlowingRedstoneOre  = 74
+	RedstoneTorchOff    = 75
+	RedstoneTorch       = 76
+	StoneButton         = 77
+	Snow                = 78
+	Ice                 = 79
+	SnowBlock           = 80
+	Cactus              = 81
+	Clay                = 82
+	Reeds               = 83
+	Jukebox             = 84
+	Fence               = 85
+	Pumpkin             = 86
+	Netherrack          = 87
+	SoulSand            = 88
+	Glowstone           = 89
+	Portal              = 90
+	JackOLantern        = 91
+)
+
+// Item ids, for things that aren't blocks.
+const (
+	IronShovel        = 256
+	IronPickaxe       = 257
+	IronAxe           = 258
+	FlintAndSteel     = 259
+	Apple             = 260
+	Bow               = 261
+	Arrow             = 262
+	Coal              = 263
+	Diamond           = 264
+	IronIngot         = 265
+	GoldIngot         = 266
+	IronSword         = 267
+	WoodenSword       = 268
+	WoodenShovel      = 269
+	WoodenPickaxe     = 270
+	WoodenAxe         = 271
+	StoneSword        = 272
+	StoneShovel       = 273
+	StonePickaxe      = 274
+	StoneAxe          = 275
+	DiamondSword      = 276
+	DiamondShovel     = 277
+	DiamondPickaxe    = 278
+	DiamondAxe        = 279
+	Stick             = 280
+	Bowl              = 281
+	MushroomSoup      = 282
+	GoldSword         = 283
+	GoldShovel        = 284
+	GoldPickaxe       = 285
+	GoldAxe           = 286
+	String            = 287
+	Feather           = 288
+	Gunpowder         = 289
+	WoodenHoe         = 290
+	StoneHoe          = 291
+	IronHoe           = 292
+	DiamondHoe        = 293
+	GoldHoe           = 294
+	Seeds             = 295
+	Wheat             = 296
+	Bread             = 297
+	LeatherCap        = 298
+	LeatherTunic      = 299
+	LeatherPants      = 300
+	LeatherBoots      = 301
+	ChainHelmet       = 302
+	ChainChestplate   = 303
+	ChainLeggings     = 304
+	ChainBoots        = 305
+	IronHelmet        = 306
+	IronChestplate    = 307
+	IronLeggings      = 308
+	IronBoots         = 309
+	DiamondHelmet     = 310
+	DiamondChestplate = 311
+	DiamondLeggings   = 312
+	DiamondBoots      = 313
+	GoldHelmet        = 314
+	GoldChestplate    = 315
+	GoldLeggings      = 316
+	GoldBoots         = 317
+	Flint             = 318
+	RawPorkchop       = 319
+	CookedPorkchop    = 320
+	Painting          = 321
+	GoldenApple       = 322
+	Sign              = 323
+	WoodenDoorItem    = 324
+	Bucket            = 325
+	WaterBucket       = 326
+	LavaBucket        = 327
+	Minecart          = 328
+	Saddle            = 329
+	IronDoorItem      = 330
+	Redstone          = 331
+	Snowball          = 332
+	Boat              = 333
+	Leather           = 334
+	MilkBucket        = 335
+	ClayBrick         = 336
+	ClayBall          = 337
+	Sugarcane         = 338
+	Paper             = 339
+	Book              = 340
+	Slimeball         = 341
+	StorageMinecart   = 342
+	PoweredMinecart   = 343
+	Egg               = 344
+	Compass           = 345
+	FishingRod        = 346
+	Clock             = 347
+	GlowstoneDust     = 348
+	RawFish           = 349
+	CookedFish        = 350
+	GoldRecord        = 2256
+	GreenRecord       = 2257
+)
+
+// Drop for blocks that leave nothing behind when mined.
+const Nothing = -1
+
+type Block struct {
+	Id   byte
+	Name string
+	// Whether things bump into it, rather than passing through.
+	Solid bool
+	// Whether the faces of blocks behind it can be seen through it.
+	Transparent bool
+	// How much light it takes away from light passing through it, out of 15.
+	Opacity byte
+	// How much light it gives off, out of 15.
+	Light byte
+	// How long it takes to mine, roughly in seconds with bare hands.  -1 for blocks
+	// that can't be mined.
+	Hardness float32
+	// The item id it drops when mined, or Nothing.
+	Drop int16
+}
+
+type Item struct {
+	Id   int16
+	Name string
+	// How many fit in one inventory slot.
+	MaxStack int8
+}
+
+// id, name, solid, transparent, opacity, light, hardness, drop
+var blockList = []Block{
+	{Air, "air", false, true, 0, 0, 0, Nothing},
+	{Stone, "stone", true, false, 15, 0, 1.5, Cobblestone},
+	{Grass, "grass", true, false, 15, 0, 0.6, Dirt},
+	{Dirt, "dirt", true, false, 15, 0, 0.5, Dirt},
+	{Cobblestone, "cobblestone", true, false, 15, 0, 2, Cobblestone},
+	{Planks, "planks", true, false, 15, 0, 2, Planks},
+	{Sapling, "sapling", false, true, 0, 0, 0, Sapling},
+	{Bedrock, "bedrock", true, false, 15, 0, -1, Nothing},
+	{Water, "water", false, true, 3, 0, 100, Nothing},
+	{StillWater, "still water", false, true, 3, 0, 100, Nothing},
+	{Lava, "lava", false, false, 15, 15, 100, Nothing},
+	{StillLava, "still lava", false, false, 15, 15, 100, Nothing},
+	{Sand, "sand", true, false, 15, 0, 0.5, Sand},
+	{Gravel, "gravel", true, false, 15, 0, 0.6, Gravel},
+	{GoldOre, "gold ore", true, false, 15, 0, 3, GoldOre},
+	{IronOre, "iron ore", true, false, 15, 0, 3, IronOre},
+	{CoalOre, "coal ore", true, false, 15, 0, 3, Coal},
+	{Log, "log", true, false, 15, 0, 2, Log},
+	{Leaves, "leaves", true, true, 1, 0, 0.2, Sapling},
+	{Sponge, "sponge", true, false, 15, 0, 0.6, Sponge},
+	{Glass, "glass", true, true, 0, 0, 0.3, Nothing},
+	{Wool, "wool", true, false, 15, 0, 0.8, Wool},
+	{Dandelion, "dandelion", false, true, 0, 0, 0, Dandelion},
+	{Rose, "rose", false, true, 0, 0, 0, Rose},
+	{BrownMushroom, "brown mushroom", false, true, 0, 1, 0, BrownMushroom},
+	{RedMushroom, "red mushroom", false, true, 0, 0, 0, RedMushroom},
+	{GoldBlock, "gold block", true, false, 15, 0, 3, GoldBlock},
+	{IronBlock, "iron block", true, false, 15, 0, 5, IronBlock},
+	{DoubleSlab, "double slab", true, false, 15, 0, 2, Slab},
+	{Slab, "slab", true, false, 15, 0, 2, Slab},
+	{Brick, "brick", true, false, 15, 0, 2, Brick},
+	{TNT, "tnt", true, false, 15, 0, 0, TNT},
+	{Bookshelf, "bookshelf", true, false, 15, 0, 1.5, Nothing},
+	{MossyCobblestone, "mossy cobblestone", true, false, 15, 0, 2, MossyCobblestone},
+	{Obsidian, "obsidian", true, false, 15, 0, 10, Obsidian},
+	{Torch, "torch", false, true, 0, 14, 0, Torch},
+	{Fire, "fire", false, true, 0, 15, 0, Nothing},
+	{MobSpawner, "mob spawner", true, true, 15, 0, 5, Nothing},
+	{WoodenStairs, "wooden stairs", true, false, 15, 0, 2, WoodenStairs},
+	{Chest, "chest", true, false, 15, 0, 2.5, Chest},
+	{RedstoneWire, "redstone wire", false, true, 0, 0, 0, Redstone},
+	{DiamondOre, "diamond ore", true, false, 15, 0, 3, Diamond},
+	{DiamondBlock, "diamond block", true, false, 15, 0, 5, DiamondBlock},
+	{Workbench, "workbench", true, false, 15, 0, 2.5, Workbench},
+	{Crops, "crops", false, true, 0, 0, 0, Seeds},
+	{Farmland, "farmland", true, false, 15, 0, 0.6, Dirt},
+	{Furnace, "furnace", true, false, 15, 0, 3.5, Furnace},
+	{BurningFurnace, "burning furnace", true, false, 15, 13, 3.5, Furnace},
+	{SignPost, "sign post", false, true, 0, 0, 1, Sign},
+	{WoodenDoor, "wooden door", true, true, 0, 0, 3, WoodenDoorItem},
+	{Ladder, "ladder", false, true, 0, 0, 0.4, Ladder},
+	{Rails, "rails", false, true, 0, 0, 0.7, Rails},
+	{CobblestoneStairs, "cobblestone stairs", true, false, 15, 0, 2, CobblestoneStairs},
+	{WallSign, "wall sign", false, true, 0, 0, 1, Sign},
+	{Lever, "lever", false, true, 0, 0, 0.5, Lever},
+	{StonePressurePlate, "stone pressure plate", false, true, 0, 0, 0.5, StonePressurePlate},
+	{IronDoor, "iron door", true, true, 0, 0, 5, IronDoorItem},
+	{WoodenPressurePlate, "wooden pressure plate", false, true, 0, 0, 0.5, WoodenPressurePlate},
+	{RedstoneOre, "redstone ore", true, false, 15, 0, 3, Redstone},
+	{GlowingRedstoneOre, "glowing redstone ore", true, false, 15, 9, 3, Redstone},
+	{RedstoneTorchOff, "redstone torch (off)", false, true, 0, 0, 0, RedstoneTorch},
+	{RedstoneTorch, "redstone torch", false, true, 0, 7, 0, RedstoneTorch},
+	{StoneButton, "stone button", false, true, 0, 0, 0.5, StoneButton},
+	{Snow, "snow", false, true, 0, 0, 0.1, Nothing},
+	{Ice, "ice", true, true, 3, 0, 0.5, Nothing},
+	{SnowBlock, "snow block", true, false, 15, 0, 0.2, Snowball},
+	{Cactus, "cactus", true, false, 15, 0, 0.4, Cactus},
+	{Clay, "clay", true, false, 15, 0, 0.6, ClayBall},
+	{Reeds, "reeds", false, true, 0, 0, 0, Sugarcane},
+	{Jukebox, "jukebox", true, false, 15, 0, 2, Jukebox},
+	{Fence, "fence", true, true, 0, 0, 2, Fence},
+	{Pumpkin, "pumpkin", true, false, 15, 0, 1, Pumpkin},
+	{Netherrack, "netherrack", true, false, 15, 0, 0.4, Netherrack},
+	{SoulSand, "soul sand", true, false, 15, 0, 0.5, SoulSand},
+	{Glowstone, "glowstone", true, false, 15, 15, 0.3, GlowstoneDust},
+	{Portal, "portal", false, true, 0, 11, -1, Nothing},
+	{JackOLantern, "jack-o-lantern", true, false, 15, 15, 1, JackOLantern},
+}
+
+// id, name, max stack
+var itemList = []Item{
+	{IronShovel, "iron shovel", 1},
+	{IronPickaxe, "iron pickaxe", 1},
+	{IronAxe, "iron axe", 1},
+	{FlintAndSteel, "flint and steel", 1},
+	{Apple, "apple", 1},
+	{Bow, "bow", 1},
+	{Arrow, "arrow", 64},
+	{Coal, "coal", 64},
+	{Diamond, "diamond", 64},
+	{IronIngot, "iron ingot", 64},
+	{GoldIngot, "gold ingot", 64},
+	{IronSword, "iron sword", 1},
+	{WoodenSword, "wooden sword", 1},
+	{WoodenShovel, "wooden shovel", 1},
+	{WoodenPickaxe, "wooden pickaxe", 1},
+	{WoodenAxe, "wooden axe", 1},
+	{StoneSword, "stone sword", 1},
+	{StoneShovel, "stone shovel", 1},
+	{StonePickaxe, "stone pickaxe", 1},
+	{StoneAxe, "stone axe", 1},
+	{DiamondSword, "diamond sword", 1},
+	{DiamondShovel, "diamond shovel", 1},
+	{DiamondPickaxe, "diamond pickaxe", 1},
+	{DiamondAxe, "diamond axe", 1},
+	{Stick, "stick", 64},
+	{Bowl, "bowl", 64},
+	{MushroomSoup, "mushroom soup", 1},
+	{GoldSword, "gold sword", 1},
+	{GoldShovel, "gold shovel", 1},
+	{GoldPickaxe, "gold pickaxe", 1},
+	{GoldAxe, "gold axe", 1},
+	{String, "string", 64},
+	{Feather, "feather", 64},
+	{Gunpowder, "gunpowder", 64},
+	{WoodenHoe, "wooden hoe", 1},
+	{StoneHoe, "stone hoe", 1},
+	{IronHoe, "iron hoe", 1},
+	{DiamondHoe, "diamond hoe", 1},
+	{GoldHoe, "gold hoe", 1},
+	{Seeds, "seeds", 64},
+	{Wheat, "wheat", 64},
+	{Bread, "bread", 1},
+	{LeatherCap, "leather cap", 1},
+	{LeatherTunic, "leather tunic", 1},
+	{LeatherPants, "leather pants", 1},
+	{LeatherBoots, "leather boots", 1},
+	{ChainHelmet, "chain helmet", 1},
+	{ChainChestplate, "chain chestplate", 1},
+	{ChainLeggings, "chain leggings", 1},
+	{ChainBoots, "chain boots", 1},
+	{IronHelmet, "iron helmet", 1},
+	{IronChestplate, "iron chestplate", 1},
+	{IronLeggings, "iron leggings", 1},
+	{IronBoots, "iron boots", 1},
+	{DiamondHelmet, "diamond helmet", 1},
+	{DiamondChestplate, "diamond chestplate", 1},
+	{DiamondLeggings, "diamond leggings", 1},
+	{DiamondBoots, "diamond boots", 1},
+	{GoldHelmet, "gold helmet", 1},
+	{GoldChestplate, "gold chestplate", 1},
+	{GoldLeggings, "gold leggings", 1},
+	{GoldBoots, "gold boots", 1},
+	{Flint, "flint", 64},
+	{RawPorkchop, "raw porkchop", 1},
+	{CookedPorkchop, "cooked porkchop", 1},
+	{Painting, "painting", 64},
+	{GoldenApple, "golden apple", 1},
+	{Sign, "sign", 1},
+	{WoodenDoorItem, "wooden door", 1},
+	{Bucket, "bucket", 1},
+	{WaterBucket, "water bucket", 1},
+	{LavaBucket, "lava bucket", 1},
+	{Minecart, "minecart", 1},
+	{Saddle, "saddle", 1},
+	{IronDoorItem, "iron door", 1},
+	{Redstone, "redstone", 64},
+	{Snowball, "snowball", 16},
+	{Boat, "boat", 1},
+	{Leather, "leather", 64},
+	{MilkBucket, "milk bucket", 1},
+	{ClayBrick, "clay brick", 64},
+	{ClayBall, "clay ball", 64},
+	{Sugarcane, "sugar cane", 64},
+	{Paper, "paper", 64},
+	{Book, "book", 64},
+	{Slimeball, "slimeball", 64},
+	{StorageMinecart, "storage minecart", 1},
+	{PoweredMinecart, "powered minecart", 1},
+	{Egg, "egg", 16},
+	{Compass, "compass", 64},
+	{FishingRod, "fishing rod", 1},
+	{Clock, "clock", 64},
+	{GlowstoneDust, "glowstone dust", 64},
+	{RawFish, "raw fish", 1},
+	{CookedFish, "cooked fish", 1},
+	{GoldRecord, "gold record", 1},
+	{GreenRecord, "green record", 1},
+}
+
+// Every id has an entry, so lookups can't fail; ids that aren't Alpha blocks get a
+// made up one.
+var blocks [256]*Block
+var known [256]bool
+var blockNames = make(map[string]*Block)
+var items = make(map[int16]*Item)
+var itemNames = make(map[string]*Item)
+
+func init() {
+	for i := range blockList {
+		b := &blockList[i]
+		blocks[b.Id] = b
+		known[b.Id] = true
+		blockNames[b.Name] = b
+		item := &Item{int16(b.Id), b.Name, 64}
+		items[item.Id] = item
+		itemNames[item.Name] = item
+	}
+	for i := range itemList {
+		item := &itemList[i]
+		items[item.Id] = item
+		// doors share a name with their block, but it's the item people carry about.
+		itemNames[item.Name] = item
+	}
+	for id := range blocks {
+		if blocks[id] == nil {
+			// something we don't know about; assume it's an ordinary block.
+			blocks[id] = &Block{byte(id), fmt.Sprint("unknown block ", id), true, false, 15, 0, -1, Nothing}
+		}
+	}
+}
+
+// Returns the block with the given id.  Ids that aren't Alpha blocks give an opaque,
+// solid, unmineable block.
+func GetBlock(id byte) *Block {
+	return blocks[id]
+}
+
+// Whether id is an Alpha block.
+func IsBlock(id byte) bool {
+	return known[id]
+}
+
+// Returns the block called name, or nil if there isn't one.
+func BlockByName(name string) *Block {
+	return blockNames[name]
+}
+
+// Returns the item with the given id, or nil if there isn't one.  Blocks are items too.
+func GetItem(id int16) *Item {
+	return items[id]
+}
+
+// Returns the item called name, or nil if there isn't one.
+func ItemByName(name string) *Item {
+	return itemNames[name]
+}
@@ -0,0 +1,39 @@
+package registry
+
+import "testing"
+
+func TestBlocks(t *testing.T) {
+	if b := GetBlock(Torch); b.Name != "torch" || b.Light != 14 || b.Solid {
+		t.Error("expected a torch to give off light and be walked through, got ", b)
+	}
+	if b := BlockByName("still water"); b == nil || b.Id != StillWater {
+		t.Error("expected to find still water by name, got ", b)
+	}
+	if IsBlock(200) {
+		t.Error("200 isn't an Alpha block")
+	}
+	if b := GetBlock(200); b.Opacity != 15 || !b.Solid {
+		t.Error("expected unknown blocks to be opaque and solid, got ", b)
+	}
+	for id := 0; id < 256; id++ {
+		b := GetBlock(byte(id))
+		if b.Drop != Nothing && GetItem(b.Drop) == nil {
+			t.Errorf("%s drops %d, which isn't an item", b.Name, b.Drop)
+		}
+	}
+}
+
+func TestItems(t *testing.T) {
+	if item := GetItem(Stone); item == nil || item.Name != "stone" || item.MaxStack != 64 {
+		t.Error("expected blocks to be items too, got ", item)
+	}
+	if item := GetItem(DiamondPickaxe); item == nil || item.MaxStack != 1 {
+		t.Error("expected tools not to stack, got ", item)
+	}
+	if item := ItemByName("wooden door"); item == nil || item.Id != WoodenDoorItem {
+		t.Error("expected the wooden door item rather than the block, got ", item)
+	}
+	if GetItem(1000) != nil {
+		t.Error("1000 isn't an item")
+	}
+}
@@ -0,0 +1,342 @@
+// Top-down maps of worlds.
+
+package render
+
+import "minecraft/world"
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "image"
+import "image/png"
+import "os"
+
+type Mode int
+
+const (
+	// What you'd see flying over the world, shaded by height.
+	Surface Mode = iota
+	// The highest cave floor under each column, shaded by depth.
+	Caves
+	// Every block at one height, as if the world had been cut through.
+	Slice
+)
+
+type Options struct {
+	Mode Mode
+	// The height to cut at in Slice mode.
+	SliceY int
+}
+
+// Tiles are square groups of chunks, rendered to one image each.
+const (
+	TileChunks = 32
+	TilePixels = TileChunks * world.ChunkSizeX
+)
+
+var transparent = image.RGBAColor{0, 0, 0, 0}
+var black = image.RGBAColor{0, 0, 0, 255}
+
+func rgb(r uint8, g uint8, b uint8) image.RGBAColor {
+	return image.RGBAColor{r, g, b, 255}
+}
+
+// Colours for each block id, roughly the average colour of its top texture.
+var palette = map[byte]image.RGBAColor{
+	registry.Stone:               rgb(125, 125, 125),
+	registry.Grass:               rgb(95, 159, 53),
+	registry.Dirt:                rgb(134, 96, 67),
+	registry.Cobblestone:         rgb(115, 115, 115),
+	registry.Planks:              rgb(157, 128, 79),
+	registry.Sapling:             rgb(120, 200, 80),
+	registry.Bedrock:             rgb(60, 60, 60),
+	registry.Water:               rgb(47, 67, 244),
+	registry.StillWater:          rgb(47, 67, 244),
+	registry.Lava:                rgb(230, 90, 0),
+	registry.StillLava:           rgb(230, 90, 0),
+	registry.Sand:                rgb(218, 210, 158),
+	registry.Gravel:              rgb(136, 126, 126),
+	registry.GoldOre:             rgb(180, 170, 100),
+	registry.IronOre:             rgb(170, 150, 140),
+	registry.CoalOre:             rgb(90, 90, 90),
+	registry.Log:                 rgb(102, 81, 51),
+	registry.Leaves:              rgb(60, 140, 40),
+	registry.Sponge:              rgb(195, 195, 80),
+	registry.Glass:               rgb(200, 230, 240),
+	registry.Wool:                rgb(220, 220, 220),
+	registry.Dandelion:           rgb(240, 240, 0),
+	registry.Rose:                rgb(240, 0, 0),
+	registry.BrownMushroom:       rgb(140, 110, 80),
+	registry.RedMushroom:         rgb(200, 40, 40),
+	registry.GoldBlock:           rgb(250, 230, 80),
+	registry.IronBlock:           rgb(220, 220, 220),
+	registry.DoubleSlab:          rgb(160, 160, 160),
+	registry.Slab:                rgb(160, 160, 160),
+	registry.Brick:               rgb(150, 80, 70),
+	registry.TNT:                 rgb(200, 50, 50),
+	registry.Bookshelf:           rgb(150, 120, 80),
+	registry.MossyCobblestone:    rgb(90, 110, 90),
+	registry.Obsidian:            rgb(20, 18, 30),
+	registry.Torch:               rgb(255, 220, 100),
+	registry.Fire:                rgb(255, 150, 0),
+	registry.MobSpawner:          rgb(30, 60, 80),
+	registry.WoodenStairs:        rgb(157, 128, 79),
+	registry.Chest:               rgb(160, 110, 40),
+	registry.RedstoneWire:        rgb(180, 0, 0),
+	registry.DiamondOre:          rgb(130, 200, 200),
+	registry.DiamondBlock:        rgb(100, 220, 220),
+	registry.Workbench:           rgb(120, 80, 50),
+	registry.Crops:               rgb(130, 160, 50),
+	registry.Farmland:            rgb(110, 70, 40),
+	registry.Furnace:             rgb(100, 100, 100),
+	registry.BurningFurnace:      rgb(100, 100, 100),
+	registry.SignPost:            rgb(157, 128, 79),
+	registry.WoodenDoor:          rgb(130, 100, 60),
+	registry.Ladder:              rgb(140, 110, 60),
+	registry.Rails:               rgb(130, 120, 110),
+	registry.CobblestoneStairs:   rgb(115, 115, 115),
+	registry.WallSign:            rgb(157, 128, 79),
+	registry.Lever:               rgb(110, 90, 60),
+	registry.StonePressurePlate:  rgb(125, 125, 125),
+	registry.IronDoor:            rgb(190, 190, 190),
+	registry.WoodenPressurePlate: rgb(157, 128, 79),
+	registry.RedstoneOre:         rgb(140, 100, 100),
+	registry.GlowingRedstoneOre:  rgb(140, 100, 100),
+	registry.RedstoneTorchOff:    rgb(120, 0, 0),
+	registry.RedstoneTorch:       rgb(200, 0, 0),
+	registry.StoneButton:         rgb(125, 125, 125),
+	registry.Snow:                rgb(245, 250, 250),
+	registry.Ice:                 rgb(160, 190, 255),
+	registry.SnowBlock:           rgb(240, 250, 250),
+	registry.Cactus:              rgb(20, 120, 30),
+	registry.Clay:                rgb(160, 165, 180),
+	registry.Reeds:               rgb(150, 200, 100),
+	registry.Jukebox:             rgb(110, 75, 55),
+	registry.Fence:               rgb(157, 128, 79),
+	registry.Pumpkin:             rgb(200, 120, 20),
+	registry.Netherrack:          rgb(110, 50, 50),
+	registry.SoulSand:            rgb(85, 65, 50),
+	registry.Glowstone:           rgb(250, 220, 130),
+	registry.Portal:              rgb(90, 20, 160),
+	registry.JackOLantern:        rgb(220, 140, 20),
+}
+
+// Blocks we don't have a colour for are painted magenta, so they stand out.
+func blockColor(id byte) image.RGBAColor {
+	if c, ok := palette[id]; ok {
+		return c
+	}
+	return rgb(255, 0, 255)
+}
+
+func isWater(id byte) bool {
+	return id == registry.Water || id == registry.StillWater
+}
+
+func clamp(f float64) uint8 {
+	if f < 0 {
+		return 0
+	}
+	if f > 255 {
+		return 255
+	}
+	return uint8(f)
+}
+
+func shade(c image.RGBAColor, f float64) image.RGBAColor {
+	return image.RGBAColor{clamp(float64(c.R) * f), clamp(float64(c.G) * f), clamp(float64(c.B) * f), c.A}
+}
+
+// t of the way from a to b.
+func blend(a image.RGBAColor, b image.RGBAColor, t float64) image.RGBAColor {
+	mix := func(x uint8, y uint8) uint8 {
+		return clamp(float64(x)*(1-t) + float64(y)*t)
+	}
+	return image.RGBAColor{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// Brighter up high, darker down low.
+func heightShade(y int) float64 {
+	return 0.6 + 0.5*float64(y)/world.ChunkSizeY
+}
+
+// Returns the y of the highest block that isn't air, or -1 for an empty column.
+func top(level *world.Level, x int, z int) int {
+	// nothing opaque is above the height map, but torches, flowers and the like may be.
+	for y := world.ChunkSizeY - 1; y >= level.Height(x, z); y-- {
+		if level.Block(x, y, z) != registry.Air {
+			return y
+		}
+	}
+	return level.Height(x, z) - 1
+}
+
+func surfaceColor(level *world.Level, x int, z int) image.RGBAColor {
+	y := top(level, x, z)
+	if y < 0 {
+		return black
+	}
+	id := level.Block(x, y, z)
+	if !isWater(id) {
+		c := shade(blockColor(id), heightShade(y))
+		// a little relief: slopes facing north-west catch the light.
+		if x > 0 {
+			switch nh := top(level, x-1, z); {
+			case nh < y:
+				c = shade(c, 1.1)
+			case nh > y:
+				c = shade(c, 0.9)
+			}
+		}
+		return c
+	}
+	// show the bottom through shallow water.
+	depth := 0
+	for y >= 0 && isWater(level.Block(x, y, z)) {
+		y--
+		depth++
+	}
+	water := shade(blockColor(registry.Water), heightShade(y+depth))
+	if y < 0 {
+		return water
+	}
+	t := 0.5 + float64(depth)/16
+	if t > 1 {
+		t = 1
+	}
+	return blend(shade(blockColor(level.Block(x, y, z)), heightShade(y)), water, t)
+}
+
+func caveColor(level *world.Level, x int, z int) image.RGBAColor {
+	for y := level.Height(x, z) - 2; y > 0; y-- {
+		if level.Block(x, y, z) == registry.Air && level.Block(x, y-1, z) != registry.Air {
+			// deep caves are blue, shallow ones green.
+			t := float64(y) / world.ChunkSizeY
+			return blend(rgb(20, 40, 160), rgb(160, 240, 120), t*1.5)
+		}
+	}
+	return black
+}
+
+func sliceColor(level *world.Level, x int, z int, y int) image.RGBAColor {
+	if y < 0 || y >= world.ChunkSizeY {
+		return transparent
+	}
+	id := level.Block(x, y, z)
+	if id == registry.Air {
+		return transparent
+	}
+	return blockColor(id)
+}
+
+func columnColor(level *world.Level, x int, z int, opts *Options) image.RGBAColor {
+	switch opts.Mode {
+	case Caves:
+		return caveColor(level, x, z)
+	case Slice:
+		return sliceColor(level, x, z, opts.SliceY)
+	}
+	return surfaceColor(level, x, z)
+}
+
+// Draws a chunk onto img with its north-west corner at (ox, oy).  x runs east
+// (to the right) and z runs south (down).
+func drawChunk(img *image.RGBA, ox int, oy int, level *world.Level, opts *Options) {
+	for x := 0; x < world.ChunkSizeX; x++ {
+		for z := 0; z < world.ChunkSizeZ; z++ {
+			img.Set(ox+x, oy+z, columnColor(level, x, z, opts))
+		}
+	}
+}
+
+// Renders one chunk as a 16x16 image.
+func Chunk(level *world.Level, opts *Options) *image.RGBA {
+	img := image.NewRGBA(world.ChunkSizeX, world.ChunkSizeZ)
+	drawChunk(img, 0, 0, level, opts)
+	return img
+}
+
+// floor(i / n), even for negative i.
+func floorDiv(i int32, n int32) int32 {
+	if i < 0 {
+		return -((-i + n - 1) / n)
+	}
+	return i / n
+}
+
+// Returns the tile a chunk is drawn in.
+func TileOf(xz world.XZ) (tx int32, tz int32) {
+	return floorDiv(xz.X(), TileChunks), floorDiv(xz.Z(), TileChunks)
+}
+
+// Loads the chunks at coords and draws each at (x - x0, z - z0) chunks from the
+// corner of img.  Chunks are unloaded again afterwards, so rendering a huge world
+// doesn't mean holding all of it in memory.
+func drawChunks(img *image.RGBA, w *world.World, coords []world.XZ, x0 int32, z0 int32, opts *Options) (lastUpdate int64, err os.Error) {
+	futures := make([]*world.ChunkFuture, len(coords))
+	for i, xz := range coords {
+		futures[i] = w.LoadChunkAsync(xz.X(), xz.Z())
+	}
+	for _, f := range futures {
+		chunk, ferr := f.Wait()
+		if ferr != nil {
+			if err == nil {
+				err = error.NewError(fmt.Sprintf("could not render chunk (%d, %d)", f.X, f.Z), ferr)
+			}
+			continue
+		}
+		drawChunk(img, int(f.X-x0)*world.ChunkSizeX, int(f.Z-z0)*world.ChunkSizeZ, &chunk.Level, opts)
+		if chunk.Level.LastUpdate > lastUpdate {
+			lastUpdate = chunk.Level.LastUpdate
+		}
+		w.UnloadChunk(f.X, f.Z)
+	}
+	return
+}
+
+// Renders the tile (tx, tz), given the chunks that are in it.  Also returns the
+// newest LastUpdate of those chunks, so callers can tell when a tile is stale.
+func Tile(w *world.World, tx int32, tz int32, coords []world.XZ, opts *Options) (img *image.RGBA, lastUpdate int64, err os.Error) {
+	img = image.NewRGBA(TilePixels, TilePixels)
+	lastUpdate, err = drawChunks(img, w, coords, tx*TileChunks, tz*TileChunks, opts)
+	return
+}
+
+// Renders the given chunks onto a single image just big enough to hold them all.
+func World(w *world.World, coords []world.XZ, opts *Options) (img *image.RGBA, err os.Error) {
+	if len(coords) == 0 {
+		return image.NewRGBA(0, 0), nil
+	}
+	minX, minZ, maxX, maxZ := coords[0].X(), coords[0].Z(), coords[0].X(), coords[0].Z()
+	for _, xz := range coords {
+		if xz.X() < minX {
+			minX = xz.X()
+		}
+		if xz.X() > maxX {
+			maxX = xz.X()
+		}
+		if xz.Z() < minZ {
+			minZ = xz.Z()
+		}
+		if xz.Z() > maxZ {
+			maxZ = xz.Z()
+		}
+	}
+	img = image.NewRGBA(int(maxX-minX+1)*world.ChunkSizeX, int(maxZ-minZ+1)*world.ChunkSizeZ)
+	_, err = drawChunks(img, w, coords, minX, minZ, opts)
+	return
+}
+
+func WritePNG(file string, img image.Image) (err os.Error) {
+	f, err := os.Open(file, os.O_WRONLY|os.O_CREAT|os.O_TRUNC, 0644)
+	if err != nil {
+		err = error.NewError("could not create image file", err)
+		return
+	}
+	defer f.Close()
+	if err = png.Encode(f, img); err != nil {
+		err = error.NewError("could not encode png", err)
+		return
+	}
+	return
+}
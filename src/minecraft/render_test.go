@@ -0,0 +1,38 @@
+package render
+
+import "minecraft/world"
+
+import "testing"
+
+func TestRenderChunk(t *testing.T) {
+	chunk, err := world.NewDefaultGenerator(7).Generate(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := Chunk(&chunk.Level, &Options{Mode: Surface})
+	if img.Bounds().Dx() != world.ChunkSizeX || img.Bounds().Dy() != world.ChunkSizeZ {
+		t.Fatal("expected a 16x16 image, got ", img.Bounds())
+	}
+	for x := 0; x < world.ChunkSizeX; x++ {
+		for z := 0; z < world.ChunkSizeZ; z++ {
+			if _, _, _, a := img.At(x, z).RGBA(); a == 0 {
+				t.Fatalf("surface at (%d, %d) is transparent", x, z)
+			}
+		}
+	}
+
+	// nothing generates above y=120, so a slice up there is empty.
+	img = Chunk(&chunk.Level, &Options{Mode: Slice, SliceY: 120})
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("expected an empty slice high in the sky")
+	}
+}
+
+func TestTileOf(t *testing.T) {
+	for _, c := range [][4]int32{{0, 0, 0, 0}, {31, 31, 0, 0}, {32, -1, 1, -1}, {-32, -33, -1, -2}} {
+		tx, tz := TileOf(world.MakeXZ(c[0], c[1]))
+		if tx != c[2] || tz != c[3] {
+			t.Errorf("chunk (%d, %d) should be in tile (%d, %d), got (%d, %d)", c[0], c[1], c[2], c[3], tx, tz)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// A block's position in world coordinates.
+type BlockPos struct {
+	X, Y, Z int32
+}
+
+// A box of blocks cut out of a world, in the format MCEdit and friends use to move
+// builds between worlds.  Entities and tile entities are positioned relative to the
+// box's lowest corner.
+// see: http://www.minecraftwiki.net/wiki/Schematic_File_Format
+type Schematic struct {
+	Width, Height, Length int16
+	// indexed by (y*Length + z)*Width + x.  Data is a whole byte per block.
+	Blocks, Data []byte
+	Entities     []Entity
+	TileEntities []TileEntity
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
+}
+
+func (s *Schematic) index(x int, y int, z int) int {
+	return (y*int(s.Length)+z)*int(s.Width) + x
+}
+
+// Returns the corners of the box from a to b, inclusive, lowest first.
+func orderCorners(a BlockPos, b BlockPos) (min BlockPos, max BlockPos) {
+	min, max = a, b
+	if min.X > max.X {
+		min.X, max.X = max.X, min.X
+	}
+	if min.Y > max.Y {
+		min.Y, max.Y = max.Y, min.Y
+	}
+	if min.Z > max.Z {
+		min.Z, max.Z = max.Z, min.Z
+	}
+	return
+}
+
+// Copies the box between the corners a and b, inclusive, into a schematic, along
+// with the entities and tile entities inside it.  Chunks are loaded as needed.
+func (world *World) ExportSchematic(a BlockPos, b BlockPos) (s *Schematic, err os.Error) {
+	min, max := orderCorners(a, b)
+	if min.Y < 0 || max.Y >= ChunkSizeY {
+		err = error.NewError(fmt.Sprintf("cannot export from y=%d to y=%d, outside the world", min.Y, max.Y), nil)
+		return
+	}
+	w, h, l := int64(max.X)-int64(min.X)+1, int64(max.Y)-int64(min.Y)+1, int64(max.Z)-int64(min.Z)+1
+	if w > 32767 || l > 32767 {
+		err = error.NewError(fmt.Sprintf("a %dx%d schematic is too big", w, l), nil)
+		return
+	}
+	s = &Schematic{
+		Width:        int16(w),
+		Height:       int16(h),
+		Length:       int16(l),
+		Blocks:       make([]byte, w*h*l),
+		Data:         make([]byte, w*h*l),
+		Entities:     []Entity{},
+		TileEntities: []TileEntity{},
+	}
+	for y := 0; y < int(h); y++ {
+		for z := 0; z < int(l); z++ {
+			for x := 0; x < int(w); x++ {
+				i := s.index(x, y, z)
+				s.Blocks[i], s.Data[i], err = world.Block(min.X+int32(x), min.Y+int32(y), min.Z+int32(z))
+				if err != nil {
+					s = nil
+					return
+				}
+			}
+		}
+	}
+
+	for cx := min.X >> 4; cx <= max.X>>4; cx++ {
+		for cz := min.Z >> 4; cz <= max.Z>>4; cz++ {
+			chunk, e := world.LoadChunkAsync(cx, cz).Wait()
+			if e != nil {
+				err = e
+				s = nil
+				return
+			}
+			for _, ent := range chunk.Level.Entities {
+				if !entityInBox(ent, min, max) {
+					continue
+				}
+				moved, e := moveEntity(ent, -min.X, -min.Y, -min.Z)
+				if e != nil {
+					err = e
+					s = nil
+					return
+				}
+				s.Entities = append(s.Entities, moved)
+			}
+			for _, te := range chunk.Level.TileEntities {
+				p := te.Base()
+				if p.X < min.X || p.X > max.X || p.Y < min.Y || p.Y > max.Y || p.Z < min.Z || p.Z > max.Z {
+					continue
+				}
+				moved, e := moveTileEntity(te, -min.X, -min.Y, -min.Z)
+				if e != nil {
+					err = e
+					s = nil
+					return
+				}
+				s.TileEntities = append(s.TileEntities, moved)
+			}
+		}
+	}
+	return
+}
+
+func entityInBox(e Entity, min BlockPos, max BlockPos) bool {
+	p := e.Base().Physics.Position
+	return p.X >= float64(min.X) && p.X < float64(max.X)+1 &&
+		p.Y >= float64(min.Y) && p.Y < float64(max.Y)+1 &&
+		p.Z >= float64(min.Z) && p.Z < float64(max.Z)+1
+}
+
+// Returns a copy of e, dx, dy and dz blocks away.
+func moveEntity(e Entity, dx int32, dy int32, dz int32) (moved Entity, err os.Error) {
+	if moved, err = toEntity(e.ToNBT()); err != nil {
+		return
+	}
+	p := &moved.Base().Physics.Position
+	p.X += float64(dx)
+	p.Y += float64(dy)
+	p.Z += float64(dz)
+	// paintings hang on a block, which has to move with them.
+	if painting, ok := moved.(*Painting); ok {
+		painting.TileX += dx
+		painting.TileY += dy
+		painting.TileZ += dz
+	}
+	return
+}
+
+// Returns a copy of te, dx, dy and dz blocks away.
+func moveTileEntity(te TileEntity, dx int32, dy int32, dz int32) (moved TileEntity, err os.Error) {
+	if moved, err = toTileEntity(te.ToNBT()); err != nil {
+		return
+	}
+	b := moved.Base()
+	b.X += dx
+	b.Y += dy
+	b.Z += dz
+	return
+}
+
+// Writes the schematic into the world with its lowest corner at origin, replacing
+// everything in the way, air included.  Tile entities already in the box are
+// removed, since they would belong to the blocks that were there.  Not safe to call
+// from several goroutines at once, like SetBlock.
+func (world *World) PasteSchematic(origin BlockPos, s *Schematic) (err os.Error) {
+	w, h, l := int(s.Width), int(s.Height), int(s.Length)
+	if len(s.Blocks) != w*h*l || len(s.Data) != w*h*l {
+		return error.NewError(fmt.Sprintf("a %dx%dx%d schematic should have %d blocks, got %d", w, h, l, w*h*l, len(s.Blocks)), nil)
+	}
+	if w == 0 || h == 0 || l == 0 {
+		return
+	}
+	max := BlockPos{origin.X + int32(w) - 1, origin.Y + int32(h) - 1, origin.Z + int32(l) - 1}
+	if origin.Y < 0 || max.Y >= ChunkSizeY {
+		return error.NewError(fmt.Sprintf("cannot paste from y=%d to y=%d, outside the world", origin.Y, max.Y), nil)
+	}
+
+	for cx := origin.X >> 4; cx <= max.X>>4; cx++ {
+		for cz := origin.Z >> 4; cz <= max.Z>>4; cz++ {
+			chunk, e := world.LoadChunkAsync(cx, cz).Wait()
+			if e != nil {
+				return e
+			}
+			kept := chunk.Level.TileEntities[:0]
+			for _, te := range chunk.Level.TileEntities {
+				p := te.Base()
+				if p.X < origin.X || p.X > max.X || p.Y < origin.Y || p.Y > max.Y || p.Z < origin.Z || p.Z > max.Z {
+					kept = append(kept, te)
+				}
+			}
+			if len(kept) != len(chunk.Level.TileEntities) {
+				chunk.Level.TileEntities = kept
+				world.markChunkDirty(chunk)
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for z := 0; z < l; z++ {
+			for x := 0; x < w; x++ {
+				i := s.index(x, y, z)
+				if err = world.SetBlock(origin.X+int32(x), origin.Y+int32(y), origin.Z+int32(z), s.Blocks[i], s.Data[i]); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	for _, te := range s.TileEntities {
+		moved, e := moveTileEntity(te, origin.X, origin.Y, origin.Z)
+		if e != nil {
+			return e
+		}
+		b := moved.Base()
+		chunk, e := world.LoadChunkAsync(b.X>>4, b.Z>>4).Wait()
+		if e != nil {
+			return e
+		}
+		chunk.Level.TileEntities = append(chunk.Level.TileEntities, moved)
+		world.markChunkDirty(chunk)
+	}
+	for _, ent := range s.Entities {
+		moved, e := moveEntity(ent, origin.X, origin.Y, origin.Z)
+		if e != nil {
+			return e
+		}
+		p := moved.Base().Physics.Position
+		chunk, e := world.LoadChunkAsync(floorChunk(p.X), floorChunk(p.Z)).Wait()
+		if e != nil {
+			return e
+		}
+		chunk.Level.Entities = append(chunk.Level.Entities, moved)
+		world.markChunkDirty(chunk)
+	}
+	return
+}
+
+// Returns the chunk coordinate that world position f falls in.
+func floorChunk(f float64) int32 {
+	i := int32(f)
+	if float64(i) > f {
+		i--
+	}
+	return i >> 4
+}
+
+// Loads a schematic from file and pastes it with its lowest corner at origin.
+func (world *World) ImportSchematic(origin BlockPos, file string) (err os.Error) {
+	s, err := LoadSchematic(file)
+	if err != nil {
+		return
+	}
+	return world.PasteSchematic(origin, s)
+}
+
+func LoadSchematic(file string) (s *Schematic, err os.Error) {
+	_, payload, err := nbt.Load(file)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not load schematic ", file), err)
+		return
+	}
+	if s, err = toSchematic(payload); err != nil {
+		err = error.NewError(fmt.Sprint("could not understand schematic ", file), err)
+		return
+	}
+	return
+}
+
+func (s *Schematic) Save(file string) (err os.Error) {
+	if err = nbt.Save(file, "Schematic", s.toNBT()); err != nil {
+		err = error.NewError(fmt.Sprint("could not save schematic ", file), err)
+		return
+	}
+	return
+}
+
+func toSchematic(payload map[string]interface{}) (s *Schematic, err os.Error) {
+	r := newCompoundReader(payload)
+	s = &Schematic{
+		Width:  r.int16("Width"),
+		Height: r.int16("Height"),
+		Length: r.int16("Length"),
+		Blocks: r.bytes("Blocks"),
+		Data:   r.bytes("Data"),
+	}
+	if materials := r.string("Materials"); r.err == nil && materials != "" && materials != "Alpha" {
+		err = error.NewError(fmt.Sprintf("expected Alpha materials, got %q", materials), nil)
+		return
+	}
+	if s.Entities, err = toEntityList(r.list("Entities")); err != nil {
+		err = error.NewError("could not understand entities", err)
+		return
+	}
+	if s.TileEntities, err = toTileEntityList(r.list("TileEntities")); err != nil {
+		err = error.NewError("could not understand tile entities", err)
+		return
+	}
+	s.Extra = r.extra()
+	if err = r.err; err != nil {
+		return
+	}
+	if n := int(s.Width) * int(s.Height) * int(s.Length); s.Width < 0 || s.Height < 0 || s.Length < 0 || len(s.Blocks) != n || len(s.Data) != n {
+		err = error.NewError(fmt.Sprintf("a %dx%dx%d schematic should have %d blocks, got %d", s.Width, s.Height, s.Length, n, len(s.Blocks)), nil)
+		return
+	}
+	return
+}
+
+func (s *Schematic) toNBT() map[string]interface{} {
+	c := newCompound(s.Extra)
+	c["Width"] = s.Width
+	c["Height"] = s.Height
+	c["Length"] = s.Length
+	c["Materials"] = "Alpha"
+	c["Blocks"] = s.Blocks
+	c["Data"] = s.Data
+	c["Entities"] = entityListToNBT(s.Entities)
+	c["TileEntities"] = tileEntityListToNBT(s.TileEntities)
+	return c
+}
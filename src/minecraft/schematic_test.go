@@ -0,0 +1,70 @@
+package world
+
+import "minecraft/registry"
+
+import "os"
+import "path"
+import "testing"
+
+func TestSchematicRoundTrip(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	// a chest on a wool block, with a pig next to it.
+	if err := w.SetBlock(2, 100, 3, registry.Wool, 14); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetBlock(2, 101, 3, registry.Chest, 0); err != nil {
+		t.Fatal(err)
+	}
+	chunk := w.Chunk(0, 0)
+	chunk.Level.TileEntities = append(chunk.Level.TileEntities, &Chest{
+		TileEntityBase: TileEntityBase{X: 2, Y: 101, Z: 3},
+		Items:          []InventorySlot{{Slot: 0, Item: Item{Id: 264, Count: 3}}},
+	})
+	pig := &Pig{}
+	pig.Physics.Position = Position{3.5, 101, 3.5}
+	chunk.Level.Entities = append(chunk.Level.Entities, pig)
+
+	s, err := w.ExportSchematic(BlockPos{4, 102, 4}, BlockPos{1, 100, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Width != 4 || s.Height != 3 || s.Length != 3 {
+		t.Fatalf("expected a 4x3x3 schematic, got %dx%dx%d", s.Width, s.Height, s.Length)
+	}
+	if len(s.TileEntities) != 1 || s.TileEntities[0].Base().X != 1 || s.TileEntities[0].Base().Y != 1 {
+		t.Fatal("expected the chest at (1, 1, 1) in the schematic, got ", s.TileEntities)
+	}
+	if len(s.Entities) != 1 || s.Entities[0].Base().Physics.Position.X != 2.5 {
+		t.Fatal("expected the pig at x=2.5 in the schematic, got ", s.Entities)
+	}
+
+	file := path.Join(dir, "build.schematic")
+	if err = s.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	// somewhere in another chunk, straddling a chunk border.
+	origin := BlockPos{-18, 90, 30}
+	if err = w.ImportSchematic(origin, file); err != nil {
+		t.Fatal(err)
+	}
+	if id, data, err := w.Block(-17, 90, 31); err != nil || id != registry.Wool || data != 14 {
+		t.Errorf("expected red wool to be pasted, got %d:%d (%v)", id, data, err)
+	}
+	te, err := w.TileEntity(-17, 91, 31)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chest, ok := te.(*Chest); !ok || len(chest.Items) != 1 || chest.Items[0].Item.Id != 264 {
+		t.Error("expected the chest and its diamonds to be pasted, got ", te)
+	}
+	pasted := w.Chunk(-1, 1)
+	if pasted == nil || len(pasted.Level.Entities) == 0 {
+		t.Fatal("expected the pig to be pasted into chunk (-1, 1)")
+	}
+	if p := pasted.Level.Entities[len(pasted.Level.Entities)-1]; p.Id() != "Pig" || p.Base().Physics.Position.X != -15.5 {
+		t.Error("expected the pig at x=-15.5, got ", p)
+	}
+}
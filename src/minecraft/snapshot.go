@@ -0,0 +1,293 @@
+package world
+
+import "minecraft/error"
+
+import "fmt"
+import "io"
+import "io/ioutil"
+import "os"
+import "path"
+import "sort"
+import "strings"
+import "time"
+
+// Snapshots kept in a snapshot directory are named after when they were taken, in
+// UTC, with -2, -3 and so on added if several are taken in the same second.
+const snapshotTimeFormat = "20060102-150405"
+
+// Copies the world, as it is right now, into dest, which must not exist yet.
+// Unsaved changes are flushed first, and nothing is saved while the copy is made,
+// so the copy is consistent.  Files are hard linked rather than copied where
+// possible: saving replaces a file rather than writing over it, so a link keeps
+// the contents it was made with.  The copy has no session.lock.  Only worlds kept in
+// a directory can be snapshotted.
+func (world *World) Snapshot(dest string) (err os.Error) {
+	return world.snapshot(dest, "")
+}
+
+// prev, if not empty, is an earlier snapshot to link unchanged files from when
+// they can't be linked from the world itself.
+func (world *World) snapshot(dest string, prev string) (err os.Error) {
+	if _, err = world.directory(); err != nil {
+		return
+	}
+	if within(dest, world.dir) {
+		return error.NewError(fmt.Sprintf("cannot snapshot %s into itself", world.dir), nil)
+	}
+	if exists(dest) {
+		return error.NewError(fmt.Sprint(dest, " already exists"), nil)
+	}
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	if err = world.flush(); err != nil {
+		return
+	}
+	if err = copyTree(world.dir, dest, prev); err != nil {
+		os.RemoveAll(dest)
+		err = error.NewError(fmt.Sprint("could not snapshot world into ", dest), err)
+		return
+	}
+	return
+}
+
+func within(dir string, parent string) bool {
+	d, p := path.Clean(dir), path.Clean(parent)
+	return d == p || strings.HasPrefix(d, p+"/")
+}
+
+// The lock belongs to whoever has the world open, half-saved files to nobody, and
+// quarantined chunks are better forgotten.
+func skipInSnapshot(name string) bool {
+	return name == sessionlock || name == quarantinedir || strings.HasSuffix(name, ".tmp")
+}
+
+func copyTree(src string, dst string, prev string) (err os.Error) {
+	if err = os.MkdirAll(dst, 0755); err != nil {
+		return
+	}
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if skipInSnapshot(f.Name) {
+			continue
+		}
+		var p string
+		if prev != "" {
+			p = path.Join(prev, f.Name)
+		}
+		from, to := path.Join(src, f.Name), path.Join(dst, f.Name)
+		switch {
+		case f.IsDirectory():
+			err = copyTree(from, to, p)
+		case f.IsRegular():
+			err = linkOrCopy(from, to, p, f)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func linkOrCopy(src string, dst string, prev string, fi *os.FileInfo) (err os.Error) {
+	if os.Link(src, dst) == nil {
+		return
+	}
+	// probably a different filesystem.  If the last snapshot has the file as it is
+	// now, share that instead.
+	if prev != "" {
+		if pfi, e := os.Stat(prev); e == nil && pfi.IsRegular() && pfi.Size == fi.Size && pfi.Mtime_ns == fi.Mtime_ns {
+			if os.Link(prev, dst) == nil {
+				return
+			}
+		}
+	}
+	in, err := os.Open(src, os.O_RDONLY, 0000)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.Open(dst, os.O_WRONLY|os.O_CREAT|os.O_EXCL, 0644)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+	// keep the modification time, so the next snapshot can tell it hasn't changed.
+	return os.Chtimes(dst, fi.Atime_ns, fi.Mtime_ns)
+}
+
+type SnapshotInfo struct {
+	// the snapshot's directory, within the snapshot directory.
+	Name string
+	// when it was taken, in seconds since 1970.
+	Time int64
+}
+
+func parseSnapshotName(name string) (secs int64, ok bool) {
+	n := len(snapshotTimeFormat)
+	if len(name) < n {
+		return
+	}
+	t, err := time.Parse(snapshotTimeFormat, name[:n])
+	if err != nil {
+		return
+	}
+	if rest := name[n:]; rest != "" {
+		if _, err = fmt.Sscanf(rest, "-%d", new(int)); err != nil {
+			return
+		}
+	}
+	return t.Seconds(), true
+}
+
+type snapshotsByTime []SnapshotInfo
+
+func (s snapshotsByTime) Len() int {
+	return len(s)
+}
+
+func (s snapshotsByTime) Less(i int, j int) bool {
+	if s[i].Time != s[j].Time {
+		return s[i].Time < s[j].Time
+	}
+	return len(s[i].Name) < len(s[j].Name) || len(s[i].Name) == len(s[j].Name) && s[i].Name < s[j].Name
+}
+
+func (s snapshotsByTime) Swap(i int, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Returns the snapshots in dir, oldest first.  Anything in dir that isn't named
+// like a snapshot is ignored.
+func ListSnapshots(dir string) (snapshots []SnapshotInfo, err os.Error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not read snapshot directory ", dir), err)
+		return
+	}
+	for _, f := range files {
+		if secs, ok := parseSnapshotName(f.Name); ok && f.IsDirectory() {
+			snapshots = append(snapshots, SnapshotInfo{f.Name, secs})
+		}
+	}
+	sort.Sort(snapshotsByTime(snapshots))
+	return
+}
+
+// Takes a snapshot into a new subdirectory of dir, named after the time.  Files
+// that can't be linked from the world are linked from the previous snapshot in dir
+// if they haven't changed since, and only copied if they have.  Returns the new
+// snapshot's name.
+func (world *World) SnapshotInto(dir string) (name string, err os.Error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = error.NewError("could not create snapshot directory", err)
+		return
+	}
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return
+	}
+	var prev string
+	if len(snapshots) > 0 {
+		prev = path.Join(dir, snapshots[len(snapshots)-1].Name)
+	}
+	base := time.SecondsToUTC(time.Seconds()).Format(snapshotTimeFormat)
+	name = base
+	for i := 2; exists(path.Join(dir, name)); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err = world.snapshot(path.Join(dir, name), prev); err != nil {
+		name = ""
+		return
+	}
+	return
+}
+
+// Which snapshots to keep.  A snapshot is kept if any of the rules keeps it, and
+// the newest is always kept.
+type Retention struct {
+	// The newest Latest snapshots.
+	Latest int
+	// The newest snapshot from each of the last Hourly hours, Daily days and Weekly
+	// weeks that have one.
+	Hourly, Daily, Weekly int
+}
+
+const (
+	hour = 60 * 60
+	day  = 24 * hour
+	week = 7 * day
+)
+
+// Returns the names of the snapshots r keeps, given snapshots oldest first.
+func (r *Retention) keep(snapshots []SnapshotInfo) map[string]bool {
+	keep := make(map[string]bool)
+	periods := []struct {
+		seconds int64
+		count   int
+	}{{hour, r.Hourly}, {day, r.Daily}, {week, r.Weekly}}
+	for _, p := range periods {
+		seen := make(map[int64]bool)
+		for i := len(snapshots) - 1; i >= 0 && len(seen) < p.count; i-- {
+			if period := snapshots[i].Time / p.seconds; !seen[period] {
+				seen[period] = true
+				keep[snapshots[i].Name] = true
+			}
+		}
+	}
+	for i := len(snapshots) - r.Latest; i < len(snapshots); i++ {
+		if i >= 0 {
+			keep[snapshots[i].Name] = true
+		}
+	}
+	if len(snapshots) > 0 {
+		keep[snapshots[len(snapshots)-1].Name] = true
+	}
+	return keep
+}
+
+// Deletes the snapshots in dir that r doesn't keep, and returns their names.
+func PruneSnapshots(dir string, r Retention) (removed []string, err os.Error) {
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return
+	}
+	keep := r.keep(snapshots)
+	for _, s := range snapshots {
+		if keep[s.Name] {
+			continue
+		}
+		if err = os.RemoveAll(path.Join(dir, s.Name)); err != nil {
+			err = error.NewError(fmt.Sprint("could not remove snapshot ", s.Name), err)
+			return
+		}
+		removed = append(removed, s.Name)
+	}
+	return
+}
+
+// Copies the world in snapshot into dest, which must not already hold a world, so
+// it can be opened.  The snapshot itself is left as it was.
+func RestoreSnapshot(snapshot string, dest string) (err os.Error) {
+	if !exists(path.Join(snapshot, leveldat)) {
+		return error.NewError(fmt.Sprint("there is no world in ", snapshot), nil)
+	}
+	if exists(path.Join(dest, leveldat)) {
+		return error.NewError(fmt.Sprint("there is already a world in ", dest), nil)
+	}
+	// links are as safe here as when snapshotting: whoever opens the restored world
+	// replaces files rather than writing over them.
+	if err = copyTree(snapshot, dest, ""); err != nil {
+		err = error.NewError(fmt.Sprint("could not restore snapshot into ", dest), err)
+		return
+	}
+	return writeSessionLock(dest)
+}
@@ -0,0 +1,86 @@
+package world
+
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "path"
+import "reflect"
+import "testing"
+
+func TestSnapshotRestore(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 2, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+	snapshots, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(snapshots)
+
+	if err = w.SetBlock(0, 120, 0, registry.Glass, 0); err != nil {
+		t.Fatal(err)
+	}
+	first, err := w.SnapshotInto(snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetBlock(0, 120, 0, registry.Obsidian, 0); err != nil {
+		t.Fatal(err)
+	}
+	second, err := w.SnapshotInto(snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("two snapshots in the same second got the same name")
+	}
+	if exists(path.Join(snapshots, first, sessionlock)) {
+		t.Error("the session lock shouldn't be snapshotted")
+	}
+	// (1, 0) didn't change between the snapshots, so both should share its file.
+	a, err := os.Stat(path.Join(snapshots, first, "1", "0", "c.1.0.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(path.Join(snapshots, second, "1", "0", "c.1.0.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Ino != b.Ino {
+		t.Error("expected an unchanged chunk to be linked, not copied")
+	}
+
+	restored := path.Join(snapshots, "restored")
+	if err = RestoreSnapshot(path.Join(snapshots, first), restored); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if id, _, err := r.Block(0, 120, 0); err != nil || id != registry.Glass {
+		t.Errorf("expected the restored world to have glass, got %d (%v)", id, err)
+	}
+	if err = RestoreSnapshot(path.Join(snapshots, second), restored); err == nil {
+		t.Error("expected restoring over a world to fail")
+	}
+}
+
+func TestRetention(t *testing.T) {
+	// one snapshot every six hours for three days, oldest first.
+	var snapshots []SnapshotInfo
+	for i := int64(0); i < 12; i++ {
+		snapshots = append(snapshots, SnapshotInfo{string('a' + i), 1300000000 - 1300000000%day + i*6*hour})
+	}
+	keep := (&Retention{Latest: 2, Daily: 2}).keep(snapshots)
+	// the latest two, plus the last of yesterday.
+	expected := map[string]bool{"l": true, "k": true, "h": true}
+	if !reflect.DeepEqual(keep, expected) {
+		t.Errorf("expected to keep %v, kept %v", expected, keep)
+	}
+	if keep = (&Retention{}).keep(snapshots); len(keep) != 1 || !keep["l"] {
+		t.Error("expected the newest to be kept no matter what, kept ", keep)
+	}
+}
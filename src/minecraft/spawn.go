@@ -0,0 +1,112 @@
+package world
+
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+import "sort"
+
+// How far FindSafeSpawn looks, in blocks.
+const spawnSearchRadius = 32
+
+// Minecraft keeps a player's position at eye level, this far above their feet.
+const PlayerEyeHeight = 1.62
+
+// Blocks that hurt whoever stands in or on them.
+func hazardous(id byte) bool {
+	switch id {
+	case registry.Lava, registry.StillLava, registry.Fire, registry.Cactus:
+		return true
+	}
+	return false
+}
+
+// Whether a player can stand in a block without suffocating, drowning or burning.
+func passable(id byte) bool {
+	return !registry.GetBlock(id).Solid && !hazardous(id) && id != registry.Water && id != registry.StillWater
+}
+
+// Whether a player's feet can go at (x, y, z) in l.
+func safeSpawn(l *Level, x int, y int, z int) bool {
+	if y < 1 || y+1 >= ChunkSizeY {
+		return false
+	}
+	ground := l.Block(x, y-1, z)
+	return registry.GetBlock(ground).Solid && !hazardous(ground) && passable(l.Block(x, y, z)) && passable(l.Block(x, y+1, z))
+}
+
+type byDistance [][2]int32
+
+func (d byDistance) Len() int {
+	return len(d)
+}
+
+func (d byDistance) Less(i int, j int) bool {
+	return d[i][0]*d[i][0]+d[i][1]*d[i][1] < d[j][0]*d[j][0]+d[j][1]*d[j][1]
+}
+
+func (d byDistance) Swap(i int, j int) {
+	d[i], d[j] = d[j], d[i]
+}
+
+// Every (dx, dz) within spawnSearchRadius, nearest first.
+var spawnOffsets byDistance
+
+func init() {
+	for dx := int32(-spawnSearchRadius); dx <= spawnSearchRadius; dx++ {
+		for dz := int32(-spawnSearchRadius); dz <= spawnSearchRadius; dz++ {
+			if dx*dx+dz*dz <= spawnSearchRadius*spawnSearchRadius {
+				spawnOffsets = append(spawnOffsets, [2]int32{dx, dz})
+			}
+		}
+	}
+	sort.Sort(spawnOffsets)
+}
+
+// Finds somewhere a player can appear without suffocating, drowning, burning or
+// falling into the void: a 2-high gap above a solid block that won't hurt them.
+// near itself is returned if it is safe, so a spawn deliberately built underground
+// stays put.  Otherwise the nearest column within spawnSearchRadius blocks whose
+// surface, as the height map has it, is safe wins.  Chunks that can't be loaded are
+// skipped.
+func (world *World) FindSafeSpawn(near BlockPos) (spawn BlockPos, err os.Error) {
+	levels := make(map[XZ]*Level)
+	var loadErr os.Error
+	level := func(x int32, z int32) *Level {
+		xz := MakeXZ(x>>4, z>>4)
+		l, ok := levels[xz]
+		if !ok {
+			if chunk, e := world.LoadChunkAsync(xz.X(), xz.Z()).Wait(); e != nil {
+				loadErr = e
+			} else {
+				l = &chunk.Level
+			}
+			levels[xz] = l
+		}
+		return l
+	}
+
+	if l := level(near.X, near.Z); l != nil && safeSpawn(l, int(near.X&15), int(near.Y), int(near.Z&15)) {
+		return near, nil
+	}
+	for _, d := range spawnOffsets {
+		x, z := near.X+d[0], near.Z+d[1]
+		l := level(x, z)
+		if l == nil {
+			continue
+		}
+		lx, lz := int(x&15), int(z&15)
+		if y := l.Height(lx, lz); safeSpawn(l, lx, y, lz) {
+			return BlockPos{x, int32(y), z}, nil
+		}
+	}
+	err = error.NewError(fmt.Sprintf("nowhere safe to spawn within %d blocks of (%d, %d, %d)", spawnSearchRadius, near.X, near.Y, near.Z), loadErr)
+	return
+}
+
+// The safe spot nearest the world's spawn: where new players are put, and so where
+// clients should be told the spawn is.
+func (world *World) SafeSpawn() (BlockPos, os.Error) {
+	return world.FindSafeSpawn(BlockPos{world.Data.SpawnX, world.Data.SpawnY, world.Data.SpawnZ})
+}
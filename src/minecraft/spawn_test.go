@@ -0,0 +1,64 @@
+package world
+
+import "minecraft/registry"
+
+import "testing"
+
+func TestFindSafeSpawn(t *testing.T) {
+	w, err := NewFixture(1).Flat(0, 0, 1, registry.Bedrock, registry.Stone, registry.Stone, registry.Grass).
+		Block(0, 1, 0, registry.Air, 0).Block(0, 2, 0, registry.Air, 0). // a little cave
+		Block(5, 3, 5, registry.StillLava, 0).
+		Block(9, 3, 9, registry.Cactus, 0).Block(9, 4, 9, registry.Cactus, 0).
+		Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Generator = nil
+
+	for _, c := range []struct {
+		near, expected BlockPos
+	}{
+		{BlockPos{3, 4, 3}, BlockPos{3, 4, 3}},
+		// a safe spot underground is left alone.
+		{BlockPos{0, 1, 0}, BlockPos{0, 1, 0}},
+		// stuck in stone, so up to the surface.
+		{BlockPos{3, 2, 3}, BlockPos{3, 4, 3}},
+		// over lava, or on a cactus, so beside it.
+		{BlockPos{5, 4, 5}, BlockPos{5, 4, 4}},
+		{BlockPos{9, 5, 9}, BlockPos{9, 4, 8}},
+		// off the edge of the world, so back onto it.
+		{BlockPos{40, 4, 0}, BlockPos{31, 4, 0}},
+	} {
+		spawn, err := w.FindSafeSpawn(c.near)
+		if err != nil {
+			t.Errorf("FindSafeSpawn(%v): %v", c.near, err)
+			continue
+		}
+		if spawn.Y != c.expected.Y || (spawn.X-c.near.X)*(spawn.X-c.near.X)+(spawn.Z-c.near.Z)*(spawn.Z-c.near.Z) != (c.expected.X-c.near.X)*(c.expected.X-c.near.X)+(c.expected.Z-c.near.Z)*(c.expected.Z-c.near.Z) {
+			t.Errorf("FindSafeSpawn(%v): expected %v or as near, got %v", c.near, c.expected, spawn)
+		}
+	}
+
+	if _, err = w.FindSafeSpawn(BlockPos{200, 64, 200}); err == nil {
+		t.Error("expected nowhere to be safe so far from any chunk")
+	}
+}
+
+func TestNewPlayer(t *testing.T) {
+	w, err := NewFixture(1).Spawn(0, 20, 0).Flat(0, 0, 1, registry.Bedrock, registry.Dirt).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	p, err := w.NewPlayer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := p.Physics.Position; pos.X != 0.5 || int(pos.Y-PlayerEyeHeight+0.5) != 2 || pos.Z != 0.5 {
+		t.Error("expected the new player on the ground at spawn, got ", pos)
+	}
+	if p.Health != 20 {
+		t.Error("expected full health, got ", p.Health)
+	}
+}
@@ -0,0 +1,359 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "bytes"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path"
+import "strings"
+import "sync"
+
+// Where a world keeps level.dat, its chunks and its players.  Each is a blob of
+// gzipped NBT, exactly as Minecraft would write it to a file, so storage needn't
+// understand what it keeps.  Blobs handed to or returned from a Storage must not
+// be changed afterwards.  A Storage is used from several goroutines at once.
+type Storage interface {
+	Level() ([]byte, os.Error)
+	PutLevel(level []byte) os.Error
+	// Returns nil and no error if there's no chunk at (x, z).
+	Chunk(x int32, z int32) ([]byte, os.Error)
+	PutChunk(x int32, z int32, chunk []byte) os.Error
+	// Removing a chunk that isn't there is not an error.
+	RemoveChunk(x int32, z int32) os.Error
+	// Returns the coordinates of every chunk.
+	ChunkCoords() []XZ
+	// How many bytes the chunk at (x, z) takes up.
+	ChunkSize(x int32, z int32) (int64, os.Error)
+	// Returns nil and no error for a player that has never been saved.
+	Player(name string) ([]byte, os.Error)
+	PutPlayer(name string, player []byte) os.Error
+	PlayerNames() ([]string, os.Error)
+	// Takes the storage from whoever had it before, the way Minecraft does: the
+	// last to lock it owns it.
+	Lock() (SessionLock, os.Error)
+}
+
+// A hold on a Storage, from Storage.Lock.  World checks it before every write, and
+// only from one goroutine at a time.
+type SessionLock interface {
+	// Whether the lock is still ours, or someone else has locked the storage since.
+	Held() (bool, os.Error)
+	Release() os.Error
+}
+
+// Copies level.dat, every chunk and every player from src into dst.  Nothing in dst
+// is removed first.  Neither storage is locked; nobody should be using either.
+func CopyStorage(dst Storage, src Storage) (err os.Error) {
+	b, err := src.Level()
+	if err == nil {
+		err = dst.PutLevel(b)
+	}
+	if err != nil {
+		return error.NewError("could not copy level", err)
+	}
+	for _, xz := range src.ChunkCoords() {
+		x, z := xz.X(), xz.Z()
+		b, err = src.Chunk(x, z)
+		if err == nil && b != nil {
+			err = dst.PutChunk(x, z, b)
+		}
+		if err != nil {
+			return error.NewError(fmt.Sprintf("could not copy chunk (%d, %d)", x, z), err)
+		}
+	}
+	names, err := src.PlayerNames()
+	if err != nil {
+		return error.NewError("could not list players", err)
+	}
+	for _, name := range names {
+		b, err = src.Player(name)
+		if err == nil && b != nil {
+			err = dst.PutPlayer(name, b)
+		}
+		if err != nil {
+			return error.NewError(fmt.Sprint("could not copy player ", name), err)
+		}
+	}
+	return
+}
+
+func decodeNBT(b []byte) (name string, payload map[string]interface{}, err os.Error) {
+	return nbt.ReadGzipped(bytes.NewBuffer(b))
+}
+
+func encodeNBT(name string, payload map[string]interface{}) (b []byte, err os.Error) {
+	var buf bytes.Buffer
+	if err = nbt.WriteGzipped(&buf, name, payload); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// A world directory laid out the way Alpha does it: a file per chunk in base36
+// directories, and players in players/.
+type dirStorage struct {
+	dir string
+}
+
+// Keeps a world in dir, as Minecraft does.  Open uses this; it is only needed to
+// hand a directory to something that takes any Storage.
+func NewDirStorage(dir string) Storage {
+	return &dirStorage{dir}
+}
+
+// Reads file, or returns nil if it doesn't exist.
+func readFileIfExists(file string) (b []byte, err os.Error) {
+	if b, err = ioutil.ReadFile(file); err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Error == os.ENOENT {
+			return nil, nil
+		}
+	}
+	return
+}
+
+// Like nbt.Save, writes alongside the file and renames over it, so the old contents
+// are never partly overwritten.  Snapshots depend on this.
+func replaceFile(file string, b []byte) (err os.Error) {
+	dir, _ := path.Split(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	tmp := file + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return
+}
+
+func (s *dirStorage) Level() ([]byte, os.Error) {
+	return ioutil.ReadFile(path.Join(s.dir, leveldat))
+}
+
+func (s *dirStorage) PutLevel(level []byte) os.Error {
+	return replaceFile(path.Join(s.dir, leveldat), level)
+}
+
+func (s *dirStorage) Chunk(x int32, z int32) ([]byte, os.Error) {
+	return readFileIfExists(alphaChunkPath(s.dir, x, z))
+}
+
+func (s *dirStorage) PutChunk(x int32, z int32, chunk []byte) os.Error {
+	return replaceFile(alphaChunkPath(s.dir, x, z), chunk)
+}
+
+func (s *dirStorage) RemoveChunk(x int32, z int32) (err os.Error) {
+	if err = os.Remove(alphaChunkPath(s.dir, x, z)); err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Error == os.ENOENT {
+			return nil
+		}
+	}
+	return
+}
+
+func (s *dirStorage) ChunkCoords() []XZ {
+	return alphaChunkCoords(s.dir)
+}
+
+func (s *dirStorage) ChunkSize(x int32, z int32) (size int64, err os.Error) {
+	fi, err := os.Stat(alphaChunkPath(s.dir, x, z))
+	if err != nil {
+		return
+	}
+	return fi.Size, nil
+}
+
+func (s *dirStorage) Player(name string) ([]byte, os.Error) {
+	return readFileIfExists(path.Join(s.dir, playersdir, name+".dat"))
+}
+
+func (s *dirStorage) PutPlayer(name string, player []byte) os.Error {
+	return replaceFile(path.Join(s.dir, playersdir, name+".dat"), player)
+}
+
+func (s *dirStorage) PlayerNames() (names []string, err os.Error) {
+	files, err := ioutil.ReadDir(path.Join(s.dir, playersdir))
+	if err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Error == os.ENOENT {
+			return nil, nil
+		}
+		return
+	}
+	for _, f := range files {
+		if !f.IsRegular() || !strings.HasSuffix(f.Name, ".dat") {
+			continue
+		}
+		if name := f.Name[:len(f.Name)-len(".dat")]; validPlayerName(name) {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// Minecraft's locking mechanism is peculiar.  It writes the current time in
+// milliseconds since 1970 to session.lock, then watches the file for changes.  If
+// it sees one, it aborts.  This has strange implications, such as the LAST process
+// to open the world owns it, not the first.  But hey, when in Rome...
+type dirLock struct {
+	f    *os.File
+	msec int64
+}
+
+func (s *dirStorage) Lock() (l SessionLock, err os.Error) {
+	f, err := os.Open(path.Join(s.dir, sessionlock), os.O_RDWR|os.O_ASYNC, 0000)
+	if err != nil {
+		err = error.NewError(fmt.Sprint("could not open ", sessionlock), err)
+		return
+	}
+	// two locks in the same millisecond would both think they held the world, so
+	// make sure ours differs from whatever is there.
+	msec := nowMsec()
+	if old, e := nbt.ReadInt64(f); e == nil && old >= msec {
+		msec = old + 1
+	}
+	if _, err = f.Seek(0, 0); err == nil {
+		err = nbt.WriteInt64(f, msec)
+	}
+	if err != nil {
+		f.Close()
+		err = error.NewError("could not write timestamp to session lock", err)
+		return
+	}
+	return &dirLock{f, msec}, nil
+}
+
+func (l *dirLock) Held() (held bool, err os.Error) {
+	if _, err = l.f.Seek(0, 0); err != nil {
+		err = error.NewError("could not seek to beginning of session lock", err)
+		return
+	}
+	msec, err := nbt.ReadInt64(l.f)
+	if err != nil {
+		err = error.NewError("could not read timestamp from session lock", err)
+		return
+	}
+	return msec == l.msec, nil
+}
+
+func (l *dirLock) Release() os.Error {
+	return l.f.Close()
+}
+
+// Keeps a world in memory, for tests and anything else that shouldn't touch the
+// disk.  Everything is lost when it is dropped.
+type MemoryStorage struct {
+	lock    sync.Mutex
+	level   []byte
+	chunks  map[XZ][]byte
+	players map[string][]byte
+	// bumped by every Lock; a lock is held until the next one.
+	generation int64
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{chunks: make(map[XZ][]byte), players: make(map[string][]byte)}
+}
+
+func (s *MemoryStorage) Level() ([]byte, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.level == nil {
+		return nil, error.NewError(fmt.Sprint("there is no ", leveldat), nil)
+	}
+	return s.level, nil
+}
+
+func (s *MemoryStorage) PutLevel(level []byte) os.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.level = level
+	return nil
+}
+
+func (s *MemoryStorage) Chunk(x int32, z int32) ([]byte, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.chunks[MakeXZ(x, z)], nil
+}
+
+func (s *MemoryStorage) PutChunk(x int32, z int32, chunk []byte) os.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.chunks[MakeXZ(x, z)] = chunk
+	return nil
+}
+
+func (s *MemoryStorage) RemoveChunk(x int32, z int32) os.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.chunks[MakeXZ(x, z)] = nil, false
+	return nil
+}
+
+func (s *MemoryStorage) ChunkCoords() (coords []XZ) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for xz := range s.chunks {
+		coords = append(coords, xz)
+	}
+	return
+}
+
+func (s *MemoryStorage) ChunkSize(x int32, z int32) (int64, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	chunk, ok := s.chunks[MakeXZ(x, z)]
+	if !ok {
+		return 0, error.NewError(fmt.Sprintf("there is no chunk (%d, %d)", x, z), nil)
+	}
+	return int64(len(chunk)), nil
+}
+
+func (s *MemoryStorage) Player(name string) ([]byte, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.players[name], nil
+}
+
+func (s *MemoryStorage) PutPlayer(name string, player []byte) os.Error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.players[name] = player
+	return nil
+}
+
+func (s *MemoryStorage) PlayerNames() (names []string, err os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name := range s.players {
+		names = append(names, name)
+	}
+	return
+}
+
+type memoryLock struct {
+	s          *MemoryStorage
+	generation int64
+}
+
+func (s *MemoryStorage) Lock() (SessionLock, os.Error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.generation++
+	return &memoryLock{s, s.generation}, nil
+}
+
+func (l *memoryLock) Held() (bool, os.Error) {
+	l.s.lock.Lock()
+	defer l.s.lock.Unlock()
+	return l.s.generation == l.generation, nil
+}
+
+func (l *memoryLock) Release() os.Error {
+	return nil
+}
@@ -0,0 +1,322 @@
+package world
+
+import "minecraft/registry"
+
+import "bytes"
+import "io/ioutil"
+import "os"
+import "path"
+import "testing"
+
+// Puts things into s and checks they come back out, whatever kind of storage it is.
+func testStorage(t *testing.T, s Storage) {
+	if err := s.PutLevel([]byte("level")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := s.Level(); err != nil || string(b) != "level" {
+		t.Errorf("expected level back, got %q (%v)", b, err)
+	}
+	for _, xz := range []XZ{MakeXZ(0, 0), MakeXZ(-1, 40), MakeXZ(3, -3)} {
+		if err := s.PutChunk(xz.X(), xz.Z(), []byte("old")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PutChunk(xz.X(), xz.Z(), []byte("chunk")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RemoveChunk(3, -3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveChunk(100, 100); err != nil {
+		t.Error("expected removing a missing chunk to succeed, got ", err)
+	}
+	if b, err := s.Chunk(-1, 40); err != nil || string(b) != "chunk" {
+		t.Errorf("expected chunk (-1, 40) back, got %q (%v)", b, err)
+	}
+	if b, err := s.Chunk(3, -3); err != nil || b != nil {
+		t.Errorf("expected chunk (3, -3) to be gone, got %q (%v)", b, err)
+	}
+	if size, err := s.ChunkSize(0, 0); err != nil || size < int64(len("chunk")) {
+		t.Errorf("expected chunk (0, 0) to take up at least 5 bytes, got %d (%v)", size, err)
+	}
+	coords := make(map[XZ]bool)
+	for _, xz := range s.ChunkCoords() {
+		coords[xz] = true
+	}
+	if len(coords) != 2 || !coords[MakeXZ(0, 0)] || !coords[MakeXZ(-1, 40)] {
+		t.Error("expected chunks (0, 0) and (-1, 40), got ", coords)
+	}
+
+	if b, err := s.Player("notch"); err != nil || b != nil {
+		t.Errorf("expected no player, got %q (%v)", b, err)
+	}
+	if err := s.PutPlayer("notch", []byte("player")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := s.Player("notch"); err != nil || string(b) != "player" {
+		t.Errorf("expected player back, got %q (%v)", b, err)
+	}
+	if names, err := s.PlayerNames(); err != nil || len(names) != 1 || names[0] != "notch" {
+		t.Errorf("expected [notch], got %v (%v)", names, err)
+	}
+
+	first, err := s.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := first.Held(); err != nil || !held {
+		t.Errorf("expected the lock to be held, got %v (%v)", held, err)
+	}
+	second, err := s.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := first.Held(); err != nil || held {
+		t.Errorf("expected the first lock to be lost, got %v (%v)", held, err)
+	}
+	if held, err := second.Held(); err != nil || !held {
+		t.Errorf("expected the second lock to be held, got %v (%v)", held, err)
+	}
+	first.Release()
+	second.Release()
+}
+
+func TestDirStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = writeSessionLock(dir); err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, NewDirStorage(dir))
+}
+
+func TestReplaceFileFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a directory with something in it can't be renamed over.
+	file := path.Join(dir, "busy")
+	if err = os.MkdirAll(path.Join(file, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = replaceFile(file, []byte("data")); err == nil {
+		t.Error("expected an error replacing a directory")
+	}
+	if exists(file + ".tmp") {
+		t.Error("expected the temporary file to be removed")
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func tempFileStorage(t *testing.T) (s *FileStorage, dir string) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = OpenFileStorage(path.Join(dir, "world.db")); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestFileStorage(t *testing.T) {
+	s, dir := tempFileStorage(t)
+	defer os.RemoveAll(dir)
+	testStorage(t, s)
+	s.Close()
+
+	// everything should still be there when the file is opened again.
+	file := path.Join(dir, "world.db")
+	s, err := OpenFileStorage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := s.Chunk(0, 0); err != nil || string(b) != "chunk" {
+		t.Errorf("expected chunk (0, 0) after reopening, got %q (%v)", b, err)
+	}
+	if b, err := s.Chunk(3, -3); err != nil || b != nil {
+		t.Errorf("expected chunk (3, -3) to stay removed, got %q (%v)", b, err)
+	}
+	if s.Garbage() == 0 {
+		t.Error("expected the replaced chunks to be garbage")
+	}
+
+	before, _ := os.Stat(file)
+	if err = s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(file)
+	if after.Size >= before.Size || s.Garbage() != 0 {
+		t.Errorf("expected compacting to shrink the file from %d bytes, got %d with %d garbage", before.Size, after.Size, s.Garbage())
+	}
+	if b, err := s.Player("notch"); err != nil || string(b) != "player" {
+		t.Errorf("expected player after compacting, got %q (%v)", b, err)
+	}
+	if err = s.PutChunk(5, 5, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if s, err = OpenFileStorage(file); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if b, err := s.Chunk(5, 5); err != nil || string(b) != "new" {
+		t.Errorf("expected chunk (5, 5) after compacting and reopening, got %q (%v)", b, err)
+	}
+}
+
+// Two FileStorages on one file stand in for two processes sharing it.
+func TestFileStorageShared(t *testing.T) {
+	a, dir := tempFileStorage(t)
+	defer os.RemoveAll(dir)
+	defer a.Close()
+	b, err := OpenFileStorage(path.Join(dir, "world.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	first, err := a.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := first.Held(); err != nil || held {
+		t.Errorf("expected the other file's lock to take over, got %v (%v)", held, err)
+	}
+	if held, err := second.Held(); err != nil || !held {
+		t.Errorf("expected the second lock to be held, got %v (%v)", held, err)
+	}
+
+	// each appends after whatever the other wrote, rather than over it.
+	if err = a.PutChunk(0, 0, []byte("from a")); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.PutChunk(1, 0, []byte("from b")); err != nil {
+		t.Fatal(err)
+	}
+	// b only reads from here on, and still sees what a writes.
+	if err = a.PutPlayer("notch", []byte("from a")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.Chunk(0, 0); err != nil || string(got) != "from a" {
+		t.Errorf("expected b to read chunk (0, 0) from a, got %q (%v)", got, err)
+	}
+	if n := len(b.ChunkCoords()); n != 2 {
+		t.Error("expected b to see 2 chunks, got ", n)
+	}
+	if names, err := b.PlayerNames(); err != nil || len(names) != 1 || names[0] != "notch" {
+		t.Errorf("expected b to see [notch], got %v (%v)", names, err)
+	}
+
+	c, err := OpenFileStorage(path.Join(dir, "world.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, err := c.Chunk(0, 0); err != nil || string(got) != "from a" {
+		t.Errorf("expected chunk (0, 0) from a, got %q (%v)", got, err)
+	}
+	if got, err := c.Chunk(1, 0); err != nil || string(got) != "from b" {
+		t.Errorf("expected chunk (1, 0) from b, got %q (%v)", got, err)
+	}
+}
+
+func TestFileStorageTornWrite(t *testing.T) {
+	s, dir := tempFileStorage(t)
+	defer os.RemoveAll(dir)
+	if err := s.PutChunk(0, 0, []byte("whole")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutChunk(1, 0, []byte("torn")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// cut the last record short, as a crash partway through writing it would.
+	file := path.Join(dir, "world.db")
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(file, b[:len(b)-2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = OpenFileStorage(file); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if b, err := s.Chunk(0, 0); err != nil || string(b) != "whole" {
+		t.Errorf("expected chunk (0, 0) to survive, got %q (%v)", b, err)
+	}
+	if b, err := s.Chunk(1, 0); err != nil || b != nil {
+		t.Errorf("expected the torn chunk (1, 0) to be dropped, got %q (%v)", b, err)
+	}
+	// the torn record mustn't get in the way of the next one.
+	if err = s.PutChunk(2, 0, []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := s.Chunk(2, 0); err != nil || string(b) != "after" {
+		t.Errorf("expected chunk (2, 0), got %q (%v)", b, err)
+	}
+}
+
+func TestFileStorageWorld(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 9, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	if err := w.SetBlock(2, 100, 2, registry.Glass, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenFileStorage(path.Join(dir, "world.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = CopyStorage(s, NewDirStorage(dir)); err != nil {
+		t.Fatal(err)
+	}
+	w, err = OpenStorage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Data.RandomSeed != 9 {
+		t.Error("expected seed 9, got ", w.Data.RandomSeed)
+	}
+	if id, _, err := w.Block(2, 100, 2); err != nil || id != registry.Glass {
+		t.Errorf("expected glass at (2, 100, 2), got %d (%v)", id, err)
+	}
+	a, _ := NewDirStorage(dir).Chunk(-1, 0)
+	b, _ := s.Chunk(-1, 0)
+	if !bytes.Equal(a, b) {
+		t.Error("expected chunk (-1, 0) to be copied as it was")
+	}
+
+	other, err := OpenStorage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err = w.Flush(); err == nil {
+		t.Error("expected Flush to fail once the storage was opened again")
+	}
+}
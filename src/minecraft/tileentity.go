@@ -0,0 +1,221 @@
+package world
+
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// Tile entities hold the extra state of blocks like chests and signs.
+// see: http://www.minecraftwiki.net/wiki/Alpha_Level_Format/Chunk_File_Format#Tile_Entity_Format
+type TileEntity interface {
+	Id() string
+	Base() *TileEntityBase
+	// Returns the tile entity as it would be saved in a chunk.
+	ToNBT() map[string]interface{}
+}
+
+// Fields every tile entity has.
+type TileEntityBase struct {
+	X, Y, Z int32
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
+}
+
+func (b *TileEntityBase) Base() *TileEntityBase {
+	return b
+}
+
+func (b *TileEntityBase) read(r *compoundReader) {
+	b.X = r.int32("x")
+	b.Y = r.int32("y")
+	b.Z = r.int32("z")
+}
+
+func (b *TileEntityBase) toNBT(id string) map[string]interface{} {
+	c := newCompound(b.Extra)
+	c["id"] = id
+	c["x"] = b.X
+	c["y"] = b.Y
+	c["z"] = b.Z
+	return c
+}
+
+type Chest struct {
+	TileEntityBase
+	Items []InventorySlot
+}
+
+func (c *Chest) Id() string {
+	return "Chest"
+}
+
+func (c *Chest) ToNBT() map[string]interface{} {
+	payload := c.toNBT(c.Id())
+	payload["Items"] = inventoryToNBT(c.Items)
+	return payload
+}
+
+type Sign struct {
+	TileEntityBase
+	Text1, Text2, Text3, Text4 string
+}
+
+func (s *Sign) Id() string {
+	return "Sign"
+}
+
+func (s *Sign) ToNBT() map[string]interface{} {
+	payload := s.toNBT(s.Id())
+	payload["Text1"] = s.Text1
+	payload["Text2"] = s.Text2
+	payload["Text3"] = s.Text3
+	payload["Text4"] = s.Text4
+	return payload
+}
+
+type Furnace struct {
+	TileEntityBase
+	BurnTime int16
+	CookTime int16
+	Items    []InventorySlot
+}
+
+func (f *Furnace) Id() string {
+	return "Furnace"
+}
+
+func (f *Furnace) ToNBT() map[string]interface{} {
+	payload := f.toNBT(f.Id())
+	payload["BurnTime"] = f.BurnTime
+	payload["CookTime"] = f.CookTime
+	payload["Items"] = inventoryToNBT(f.Items)
+	return payload
+}
+
+type MobSpawner struct {
+	TileEntityBase
+	EntityId string
+	Delay    int16
+}
+
+func (m *MobSpawner) Id() string {
+	return "MobSpawner"
+}
+
+func (m *MobSpawner) ToNBT() map[string]interface{} {
+	payload := m.toNBT(m.Id())
+	payload["EntityId"] = m.EntityId
+	payload["Delay"] = m.Delay
+	return payload
+}
+
+// A tile entity whose id isn't registered.  It is kept exactly as it was read.
+type UnknownTileEntity struct {
+	TileEntityBase
+	id string
+}
+
+func (u *UnknownTileEntity) Id() string {
+	return u.id
+}
+
+func (u *UnknownTileEntity) ToNBT() map[string]interface{} {
+	return u.toNBT(u.id)
+}
+
+// Reads the fields particular to one kind of tile entity.  The base fields are
+// read afterwards.
+type tileEntityReader func(r *compoundReader) TileEntity
+
+var tileEntityReaders = map[string]tileEntityReader{
+	"Chest": func(r *compoundReader) TileEntity {
+		return &Chest{Items: toInventory(r, "Items")}
+	},
+	"Sign": func(r *compoundReader) TileEntity {
+		return &Sign{
+			Text1: r.string("Text1"),
+			Text2: r.string("Text2"),
+			Text3: r.string("Text3"),
+			Text4: r.string("Text4"),
+		}
+	},
+	"Furnace": func(r *compoundReader) TileEntity {
+		return &Furnace{
+			BurnTime: r.int16("BurnTime"),
+			CookTime: r.int16("CookTime"),
+			Items:    toInventory(r, "Items"),
+		}
+	},
+	"MobSpawner": func(r *compoundReader) TileEntity {
+		return &MobSpawner{
+			EntityId: r.string("EntityId"),
+			Delay:    r.int16("Delay"),
+		}
+	},
+}
+
+func toTileEntity(payload map[string]interface{}) (te TileEntity, err os.Error) {
+	r := newCompoundReader(payload)
+	id := r.string("id")
+	if read, ok := tileEntityReaders[id]; ok {
+		te = read(r)
+	} else {
+		te = &UnknownTileEntity{id: id}
+	}
+	base := te.Base()
+	base.read(r)
+	base.Extra = r.extra()
+	if r.err != nil {
+		err = error.NewError(fmt.Sprint("could not understand tile entity ", id), r.err)
+	}
+	return
+}
+
+func toTileEntityList(payload []interface{}) (tileEntities []TileEntity, err os.Error) {
+	tileEntities = make([]TileEntity, len(payload))
+	for i, v := range payload {
+		c, ok := v.(map[string]interface{})
+		if !ok {
+			err = error.NewError(fmt.Sprintf("tile entity %d is a %T, not a compound", i, v), nil)
+			return
+		}
+		if tileEntities[i], err = toTileEntity(c); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func tileEntityListToNBT(tileEntities []TileEntity) []interface{} {
+	l := make([]interface{}, len(tileEntities))
+	for i, te := range tileEntities {
+		l[i] = te.ToNBT()
+	}
+	return l
+}
+
+// Returns the tile entity for the block at chunk-relative (x, y, z), or nil if it doesn't have one.
+func (l *Level) TileEntity(x int, y int, z int) TileEntity {
+	wx, wz := l.XPos*ChunkSizeX+int32(x), l.ZPos*ChunkSizeZ+int32(z)
+	for _, te := range l.TileEntities {
+		if b := te.Base(); b.X == wx && b.Y == int32(y) && b.Z == wz {
+			return te
+		}
+	}
+	return nil
+}
+
+// Returns the tile entity for the block at world coordinates (x, y, z), loading its
+// chunk if needed.  Blocks without one give a nil TileEntity and no error.
+func (world *World) TileEntity(x int32, y int32, z int32) (te TileEntity, err os.Error) {
+	if y < 0 || y >= ChunkSizeY {
+		err = blockOutOfRange(x, y, z)
+		return
+	}
+	chunk, err := world.LoadChunkAsync(x>>4, z>>4).Wait()
+	if err != nil {
+		return
+	}
+	te = chunk.Level.TileEntity(int(x&15), int(y), int(z&15))
+	return
+}
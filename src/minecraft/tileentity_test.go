@@ -0,0 +1,46 @@
+package world
+
+import "reflect"
+import "testing"
+
+func TestTileEntityRoundTrip(t *testing.T) {
+	payloads := []interface{}{
+		map[string]interface{}{
+			"id": "Sign", "x": int32(1), "y": int32(64), "z": int32(-3),
+			"Text1": "hello", "Text2": "", "Text3": "world", "Text4": "",
+		},
+		map[string]interface{}{
+			"id": "Chest", "x": int32(2), "y": int32(64), "z": int32(-3),
+			"Items": []interface{}{
+				map[string]interface{}{"Slot": int8(3), "id": int16(264), "Count": int8(5), "Damage": int16(0)},
+			},
+		},
+		map[string]interface{}{
+			"id": "Furnace", "x": int32(3), "y": int32(64), "z": int32(-3),
+			"BurnTime": int16(100), "CookTime": int16(50), "Items": []interface{}{},
+		},
+		map[string]interface{}{
+			"id": "MobSpawner", "x": int32(4), "y": int32(20), "z": int32(-3),
+			"EntityId": "Skeleton", "Delay": int16(200),
+		},
+		map[string]interface{}{
+			"id": "Music", "x": int32(5), "y": int32(64), "z": int32(-3), "note": int8(4),
+		},
+	}
+	tes, err := toTileEntityList(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sign, ok := tes[0].(*Sign); !ok || sign.Text3 != "world" {
+		t.Error("expected a sign saying world, got ", tes[0])
+	}
+	if chest, ok := tes[1].(*Chest); !ok || len(chest.Items) != 1 || chest.Items[0].Item.Count != 5 {
+		t.Error("expected a chest with 5 diamonds, got ", tes[1])
+	}
+	if _, ok := tes[4].(*UnknownTileEntity); !ok || tes[4].Id() != "Music" {
+		t.Error("expected an unknown tile entity, got ", tes[4])
+	}
+	if saved := tileEntityListToNBT(tes); !reflect.DeepEqual(saved, payloads) {
+		t.Error("expected ", payloads, ", got ", saved)
+	}
+}
@@ -0,0 +1,261 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/error"
+
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path"
+import "strings"
+
+// Bad files are moved here, under the world directory, keeping their chunk
+// directories so it is obvious where they came from.
+const quarantinedir = "quarantine"
+
+type VerifyOptions struct {
+	// Move bad chunk files into the quarantine directory, so Minecraft
+	// regenerates them.
+	Quarantine bool
+	// Quarantine bad chunks and save freshly generated ones in their place.
+	// Needs a Generator.
+	Regenerate bool
+}
+
+// Something wrong with a file in the world.
+type Problem struct {
+	// relative to the world directory.
+	File string
+	// whether X and Z say which chunk the file was meant to be.
+	IsChunk bool
+	X, Z    int32
+	Err     os.Error
+	// what was done about it: "", "quarantined" or "regenerated".
+	Fixed string
+}
+
+func (p *Problem) String() string {
+	s := fmt.Sprintf("%s: %s", p.File, p.Err.String())
+	if p.Fixed != "" {
+		s += " (" + p.Fixed + ")"
+	}
+	return s
+}
+
+type Report struct {
+	// chunk files looked at.
+	Chunks   int
+	Problems []*Problem
+}
+
+// Checks level.dat and every chunk file, without loading anything into the
+// world.  Chunks are checked for NBT that can't be read, fields of the wrong type,
+// arrays of the wrong size, a position that doesn't match the file name, and
+// entities and tile entities that can't be understood or are in the wrong chunk.
+// Fixing problems needs a world opened for writing; if a fix fails, Verify stops and
+// returns what it found so far along with the error.  Only worlds kept in a
+// directory can be verified.
+func (world *World) Verify(options *VerifyOptions) (report *Report, err os.Error) {
+	if _, err = world.directory(); err != nil {
+		return
+	}
+	var opts VerifyOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.Regenerate {
+		opts.Quarantine = true
+		if world.Generator == nil {
+			err = error.NewError("cannot regenerate chunks without a generator", nil)
+			return
+		}
+	}
+	if opts.Quarantine {
+		if err = world.verifyWritable(); err != nil {
+			return
+		}
+	}
+
+	report = &Report{}
+	if e := verifyLevelDat(path.Join(world.dir, leveldat)); e != nil {
+		report.Problems = append(report.Problems, &Problem{File: leveldat, Err: e})
+	}
+	for _, xdir := range chunkDirs(world.dir) {
+		for _, zdir := range chunkDirs(path.Join(world.dir, xdir.Name)) {
+			dir := path.Join(xdir.Name, zdir.Name)
+			files, e := ioutil.ReadDir(path.Join(world.dir, dir))
+			if e != nil {
+				report.Problems = append(report.Problems, &Problem{File: dir, Err: e})
+				continue
+			}
+			for _, f := range files {
+				p := world.verifyFile(dir, f, report)
+				if p == nil {
+					continue
+				}
+				report.Problems = append(report.Problems, p)
+				if opts.Quarantine {
+					if err = world.fix(p, opts.Regenerate); err != nil {
+						return
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// Like Verify, for the world in dir, but works even when level.dat is too broken
+// for the world to open: level.dat is reported as a Problem and every chunk is
+// still checked.  Without level.dat there is no seed, so chunks can be quarantined
+// but not regenerated.  The world is only locked if something is to be fixed.
+func VerifyDir(dir string, options *VerifyOptions) (report *Report, err os.Error) {
+	fixing := options != nil && (options.Quarantine || options.Regenerate)
+	w, err := open(dir, !fixing)
+	if err != nil {
+		fi, e := os.Stat(dir)
+		if e != nil || !fi.IsDirectory() {
+			return
+		}
+		// check what we can without level.dat.
+		w = newWorld(NewDirStorage(dir), !fixing)
+		w.dir = dir
+		if fixing {
+			if err = w.lock(); err != nil {
+				err = error.NewError("unable to obtain lock on world", err)
+				return
+			}
+		}
+	}
+	defer w.Close()
+	return w.Verify(options)
+}
+
+// Returns nil if the file is fine, or isn't ours to judge.
+func (world *World) verifyFile(dir string, f *os.FileInfo, report *Report) *Problem {
+	file := path.Join(dir, f.Name)
+	if strings.HasSuffix(f.Name, ".tmp") {
+		return &Problem{File: file, Err: error.NewError("left over from a save that didn't finish", nil)}
+	}
+	x, z, ok := parseChunkName(f.Name)
+	if !ok || !f.IsRegular() {
+		return nil
+	}
+	report.Chunks++
+	p := &Problem{File: file, IsChunk: true, X: x, Z: z}
+	if world.chunkPath(x, z) != path.Join(world.dir, file) {
+		p.Err = error.NewError("chunk file is in the wrong directory", nil)
+	} else {
+		p.Err = verifyChunk(path.Join(world.dir, file), x, z)
+	}
+	if p.Err == nil {
+		return nil
+	}
+	return p
+}
+
+func verifyLevelDat(file string) (err os.Error) {
+	_, level, err := nbt.Load(file)
+	if err != nil {
+		return
+	}
+	r := newCompoundReader(level)
+	data := r.compound("Data")
+	if r.err != nil {
+		return r.err
+	}
+	if data == nil {
+		return error.NewError("level has no Data", nil)
+	}
+	for _, key := range []string{"Time", "SpawnX", "SpawnY", "SpawnZ", "RandomSeed"} {
+		if _, ok := data[key]; !ok {
+			return error.NewError(fmt.Sprint("level has no ", key), nil)
+		}
+	}
+	_, err = toData(data)
+	return
+}
+
+func verifyChunk(file string, x int32, z int32) (err os.Error) {
+	_, payload, err := nbt.Load(file)
+	if err != nil {
+		return
+	}
+	// toChunk checks the types and array sizes, and reads every entity.
+	chunk, err := toChunk(payload)
+	if err != nil {
+		return
+	}
+	level := &chunk.Level
+	if level.XPos != x || level.ZPos != z {
+		return error.NewError(fmt.Sprintf("chunk says it is chunk (%d, %d)", level.XPos, level.ZPos), nil)
+	}
+	for _, te := range level.TileEntities {
+		b := te.Base()
+		if b.X>>4 != x || b.Z>>4 != z || b.Y < 0 || b.Y >= ChunkSizeY {
+			return error.NewError(fmt.Sprintf("%s tile entity at (%d, %d, %d) is outside the chunk", te.Id(), b.X, b.Y, b.Z), nil)
+		}
+	}
+	return
+}
+
+func (world *World) fix(p *Problem, regenerate bool) (err os.Error) {
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	// a misplaced file isn't missing from where it should be, and isn't what's loaded there.
+	inPlace := p.IsChunk && world.chunkPath(p.X, p.Z) == path.Join(world.dir, p.File)
+	// whatever is loaded would otherwise be saved over the fix; unsaved changes are
+	// somebody's work, so they win.
+	if inPlace && !world.UnloadChunk(p.X, p.Z) {
+		err = error.NewError(fmt.Sprintf("chunk (%d, %d) has unsaved changes; Flush before fixing it", p.X, p.Z), nil)
+		return
+	}
+	if err = world.quarantine(p.File); err != nil {
+		return
+	}
+	p.Fixed = "quarantined"
+	if !inPlace {
+		return
+	}
+	if !regenerate {
+		// somebody may have loaded the bad file again before it was moved.
+		world.UnloadChunk(p.X, p.Z)
+		return
+	}
+	chunk, err := world.Generator.Generate(p.X, p.Z)
+	if err != nil {
+		err = error.NewError(fmt.Sprintf("could not generate chunk (%d, %d)", p.X, p.Z), err)
+		return
+	}
+	chunk.Level.LastUpdate = world.Data.Time
+	if err = world.saveChunk(chunk); err != nil {
+		return
+	}
+	world.chunkLock.Lock()
+	xz := MakeXZ(p.X, p.Z)
+	if loaded, ok := world.Chunks[xz]; !ok || !loaded.dirty {
+		world.Chunks[xz] = chunk
+	}
+	world.chunkLock.Unlock()
+	p.Fixed = "regenerated"
+	return
+}
+
+// Moves file, relative to the world directory, into the quarantine directory.
+// Nothing already there is overwritten.
+func (world *World) quarantine(file string) (err os.Error) {
+	dest := path.Join(world.dir, quarantinedir, file)
+	dir, _ := path.Split(dest)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = error.NewError("could not create quarantine directory", err)
+		return
+	}
+	for i := 1; exists(dest); i++ {
+		dest = fmt.Sprintf("%s.%d", path.Join(world.dir, quarantinedir, file), i)
+	}
+	if err = os.Rename(path.Join(world.dir, file), dest); err != nil {
+		err = error.NewError(fmt.Sprint("could not quarantine ", file), err)
+		return
+	}
+	return
+}
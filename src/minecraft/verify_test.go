@@ -0,0 +1,126 @@
+package world
+
+import "minecraft/nbt"
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "path"
+import "testing"
+
+// Loads a chunk's file, lets f spoil its Level, and saves it again.
+func spoilChunk(t *testing.T, w *World, x int32, z int32, f func(level map[string]interface{})) {
+	file := w.chunkPath(x, z)
+	_, payload, err := nbt.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f(payload["Level"].(map[string]interface{}))
+	if err = nbt.Save(file, "", payload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	if err := ioutil.WriteFile(w.chunkPath(1, 0), []byte("not a chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spoilChunk(t, w, -1, 0, func(level map[string]interface{}) {
+		level["Blocks"] = make([]byte, 100)
+	})
+	spoilChunk(t, w, 0, 1, func(level map[string]interface{}) {
+		level["xPos"] = int32(5)
+	})
+
+	report, err := w.Verify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Chunks != 5 || len(report.Problems) != 3 {
+		t.Fatalf("expected 3 problems in 5 chunks, got %d in %d", len(report.Problems), report.Chunks)
+	}
+	// Create left the chunk loaded; drop it so LoadChunk reads the spoiled file.
+	if !w.UnloadChunk(-1, 0) {
+		t.Fatal("expected chunk (-1, 0) to have no unsaved changes")
+	}
+	if err = w.LoadChunk(-1, 0); err == nil {
+		t.Error("expected a chunk with short Blocks to fail to load")
+	}
+
+	stale := w.Chunk(0, 1)
+	if report, err = w.Verify(&VerifyOptions{Regenerate: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range report.Problems {
+		if p.Fixed != "regenerated" {
+			t.Errorf("expected %s to be regenerated", p)
+		}
+	}
+	if !exists(path.Join(dir, quarantinedir, "1", "0", "c.1.0.dat")) {
+		t.Error("expected the unreadable chunk to be quarantined")
+	}
+	if report, err = w.Verify(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 0 {
+		t.Errorf("expected no problems after regenerating, got %v", report.Problems)
+	}
+	if chunk := w.Chunk(0, 1); chunk == nil || chunk == stale {
+		t.Error("expected the loaded chunk (0, 1) to be replaced by the regenerated one")
+	}
+	if err = w.LoadChunk(-1, 0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyUnsavedChanges(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	if err := ioutil.WriteFile(w.chunkPath(1, 0), []byte("not a chunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetBlock(16, 100, 0, registry.Stone, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Verify(&VerifyOptions{Regenerate: true}); err == nil {
+		t.Error("expected Verify to refuse to fix a chunk with unsaved changes")
+	}
+	if !exists(w.chunkPath(1, 0)) {
+		t.Error("expected the chunk file to be left where it was")
+	}
+	if chunk := w.Chunk(1, 0); chunk == nil || chunk.Level.Block(0, 100, 0) != registry.Stone {
+		t.Error("expected the unsaved change to survive")
+	}
+}
+
+func TestVerifyDirBadLevel(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	w.Close()
+	if err := ioutil.WriteFile(path.Join(dir, leveldat), []byte("not a level"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenReadOnly(dir); err == nil {
+		t.Fatal("expected a world with a broken level.dat not to open")
+	}
+
+	report, err := VerifyDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Chunks != 5 {
+		t.Error("expected all 5 chunks to be checked, got ", report.Chunks)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].File != leveldat {
+		t.Errorf("expected just a level.dat problem, got %v", report.Problems)
+	}
+	if _, err = VerifyDir(dir, &VerifyOptions{Regenerate: true}); err == nil {
+		t.Error("expected regenerating without a seed to fail")
+	}
+}
@@ -1,12 +1,14 @@
 package world
 
-import "minecraft/nbt"
 import "minecraft/error"
 
 import "fmt"
 import "io/ioutil"
 import "os"
 import "path"
+import "strings"
+import "sync"
+import "time"
 
 const (
 	leveldat    = "level.dat"
@@ -19,27 +21,65 @@ func MakeXZ(x int32, z int32) XZ {
 	return XZ(int64(x) + int64(z)<<32)
 }
 
+func (xz XZ) X() int32 {
+	return int32(xz)
+}
+
+func (xz XZ) Z() int32 {
+	// undo the borrow a negative x took from the high half.
+	return int32((int64(xz) - int64(xz.X())) >> 32)
+}
+
 type World struct {
+	storage Storage
+	// the world's directory, or "" if it isn't kept in one.
 	dir      string
-	lockmsec int64
+	readOnly bool
 	// see: http://www.minecraftwiki.net/wiki/Alpha_Level_Format
 	Data Data
+	// Fills in chunks that haven't been generated yet.  If nil, missing chunks
+	// fail to load instead.
+	Generator Generator
 	// we cheat and use int64, since it has equality defined.
-	Chunks map[XZ]*Chunk
-	lockfd *os.File
+	Chunks    map[XZ]*Chunk
+	chunkLock sync.Mutex
+	// level.dat's root tag name and anything alongside Data, kept for Flush.
+	levelName  string
+	levelExtra map[string]interface{}
+	// nil if the world was opened read-only.
+	session SessionLock
+	// chunks are loaded from several goroutines at once, and they all check the lock.
+	// Also guards closed, so the lock isn't released in the middle of a check.
+	lockCheck sync.Mutex
+	closed    bool
+	// closed once another process takes the world from us.
+	lockLost chan bool
+	lockQuit chan bool
+	loader   *chunkLoader
+	// held while writing files, so a snapshot sees all of a save or none of it.
+	saveLock sync.Mutex
 }
 
 type Data struct {
-	SnowCovered            int8
 	Time                   int64
 	SpawnX, SpawnY, SpawnZ int32
 	LastPlayed             int64
 	SizeOnDisk             int64
 	RandomSeed             int64
+	// nullables; not every version of the game writes these.
+	SnowCovered *int8
+	Version     *int32
+	LevelName   *string
+	// the single-player player.  nil for worlds that have only been played in multiplayer.
+	Player *Player
+	// anything else in Data we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
 }
 
 type Chunk struct {
 	Level Level
+	// set when the in-memory chunk differs from what's on disk.
+	dirty bool
 }
 
 type Level struct {
@@ -48,25 +88,14 @@ type Level struct {
 	SkyLight         []byte
 	HeightMap        []byte
 	BlockLight       []byte
-	Entities         []*Entity
-	TileEntities     interface{}
+	Entities         []Entity
+	TileEntities     []TileEntity
 	LastUpdate       int64
 	XPos             int32
 	ZPos             int32
 	TerrainPopulated int8
-}
-
-type Entity struct {
-	Id           string
-	OnGround     int8
-	Air          int16
-	Fire         int16
-	Health       *int16
-	Tile         *int16
-	Item         *Item
-	FallDistance float32
-	Physics      Physics
-	Age          *int16
+	// anything we don't understand, kept so it can be written back out.
+	Extra map[string]interface{}
 }
 
 type Item struct {
@@ -92,33 +121,222 @@ type Euler struct {
 }
 
 func Open(worlddir string) (w *World, err os.Error) {
-	w = &World{dir: worlddir}
+	return open(worlddir, false)
+}
+
+// Opens a world without taking the session lock, so a running game or server
+// doesn't notice (and doesn't abort).  Nothing can be written, and chunks that
+// don't exist fail to load rather than being generated.
+func OpenReadOnly(worlddir string) (w *World, err os.Error) {
+	return open(worlddir, true)
+}
+
+// Opens a world kept somewhere other than a directory, such as in memory or in a
+// FileStorage.  Takes the storage's session lock, just as Open does.
+func OpenStorage(storage Storage) (w *World, err os.Error) {
+	w = newWorld(storage, false)
+	if err = w.lock(); err != nil {
+		err = error.NewError("unable to obtain lock on world", err)
+		return
+	}
+	if err = w.load(); err != nil {
+		return
+	}
+	go w.watchLock(lockCheckInterval)
+	return
+}
+
+func newWorld(storage Storage, readOnly bool) *World {
+	return &World{storage: storage, readOnly: readOnly, lockLost: make(chan bool), lockQuit: make(chan bool)}
+}
+
+func open(worlddir string, readOnly bool) (w *World, err os.Error) {
+	w = newWorld(NewDirStorage(worlddir), readOnly)
+	w.dir = worlddir
 	if err = w.verifyFormat(); err != nil {
 		err = error.NewError("could not verify world format", err)
 		return
 	}
-	if err = w.lock(); err != nil {
-		err = error.NewError("unable to obtain lock on world", err)
+	if !readOnly {
+		if err = w.lock(); err != nil {
+			err = error.NewError("unable to obtain lock on world", err)
+			return
+		}
+		if err = w.makePlayersDir(); err != nil {
+			err = error.NewError("could not create players directory", err)
+			return
+		}
+	}
+	if err = w.load(); err != nil {
+		if w.session != nil {
+			w.unlock()
+		}
 		return
 	}
-	_, levelDat, err := nbt.Load(path.Join(w.dir, leveldat))
+	if !readOnly {
+		go w.watchLock(lockCheckInterval)
+	}
+	return
+}
+
+// Reads level.dat and gets ready to load chunks.
+func (world *World) load() (err os.Error) {
+	b, err := world.storage.Level()
 	if err != nil {
 		err = error.NewError("could not read level", err)
 		return
 	}
+	var levelDat map[string]interface{}
+	if world.levelName, levelDat, err = decodeNBT(b); err != nil {
+		err = error.NewError("could not read level", err)
+		return
+	}
+	world.Chunks = make(map[XZ]*Chunk)
+	if err = world.loadLevelDat(levelDat); err != nil {
+		err = error.NewError("could not understand level", err)
+		return
+	}
+	world.loader = newChunkLoader(world, loaderWorkers)
+	if !world.readOnly {
+		world.Generator = NewDefaultGenerator(world.Data.RandomSeed)
+	}
+	return
+}
 
-	w.Chunks = make(map[XZ]*Chunk)
-	w.loadLevelDat(levelDat)
+// Releases the world.  Closing it again does nothing.
+func (world *World) Close() (err os.Error) {
+	world.lockCheck.Lock()
+	if world.closed {
+		world.lockCheck.Unlock()
+		return
+	}
+	world.closed = true
+	if world.session != nil {
+		err = world.unlock()
+	}
+	world.lockCheck.Unlock()
+	// VerifyDir's worlds never load level.dat, so never start loading chunks.
+	if world.loader != nil {
+		world.loader.stop()
+	}
+	close(world.lockQuit)
 	return
 }
 
-func (world *World) Close() os.Error {
-	return world.unlock()
+// Returns the chunk at (x, z) to disk, freeing its memory.  Chunks with unsaved
+// changes are kept; returns whether the chunk is gone.
+func (world *World) UnloadChunk(x int32, z int32) bool {
+	world.chunkLock.Lock()
+	defer world.chunkLock.Unlock()
+	xz := MakeXZ(x, z)
+	if chunk, ok := world.Chunks[xz]; ok {
+		if chunk.dirty {
+			return false
+		}
+		world.Chunks[xz] = nil, false
+	}
+	return true
+}
+
+// Replaces the chunk at the chunk's own position with it, whether or not there
+// was one there before.  It is saved by the next Flush.
+func (world *World) PutChunk(chunk *Chunk) (err os.Error) {
+	if err = world.verifyWritable(); err != nil {
+		return
+	}
+	if err = chunk.Level.checkArrays(); err != nil {
+		return
+	}
+	world.chunkLock.Lock()
+	chunk.dirty = true
+	world.Chunks[MakeXZ(chunk.Level.XPos, chunk.Level.ZPos)] = chunk
+	world.chunkLock.Unlock()
+	return
+}
+
+// Deletes the chunk at (x, z), from memory and from disk, unsaved changes and all.
+// If the world has a Generator, the chunk is generated afresh next time it is loaded.
+func (world *World) RemoveChunk(x int32, z int32) (err os.Error) {
+	if err = world.verifyWritable(); err != nil {
+		return
+	}
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	world.chunkLock.Lock()
+	world.Chunks[MakeXZ(x, z)] = nil, false
+	world.chunkLock.Unlock()
+	if err = world.storage.RemoveChunk(x, z); err != nil {
+		err = error.NewError(fmt.Sprintf("could not remove chunk (%d, %d)", x, z), err)
+		return
+	}
+	return
 }
 
 // Flushes any in-memory changes to disk
-func (world *World) Flush() os.Error {
-	panic("writeme")
+func (world *World) Flush() (err os.Error) {
+	world.saveLock.Lock()
+	defer world.saveLock.Unlock()
+	return world.flush()
+}
+
+func (world *World) flush() (err os.Error) {
+	if err = world.verifyWritable(); err != nil {
+		return
+	}
+	world.chunkLock.Lock()
+	var dirty []*Chunk
+	for _, chunk := range world.Chunks {
+		if chunk.dirty {
+			dirty = append(dirty, chunk)
+		}
+	}
+	world.chunkLock.Unlock()
+	for _, chunk := range dirty {
+		if err = world.saveChunk(chunk); err != nil {
+			return
+		}
+	}
+	return world.saveLevel()
+}
+
+// Writes level.dat.  Callers hold saveLock.
+func (world *World) saveLevel() (err os.Error) {
+	b, err := encodeNBT(world.levelName, world.saveLevelDat())
+	if err == nil {
+		err = world.storage.PutLevel(b)
+	}
+	if err != nil {
+		err = error.NewError("could not save level", err)
+		return
+	}
+	return
+}
+
+func (world *World) saveChunk(chunk *Chunk) (err os.Error) {
+	x, z := chunk.Level.XPos, chunk.Level.ZPos
+	// clear dirty before encoding, so a change made while we write marks it again
+	// rather than being forgotten.
+	world.chunkLock.Lock()
+	chunk.dirty = false
+	world.chunkLock.Unlock()
+	b, err := encodeNBT("", chunk.toNBT())
+	if err == nil {
+		err = world.storage.PutChunk(x, z, b)
+	}
+	if err != nil {
+		world.markChunkDirty(chunk)
+		err = error.NewError(fmt.Sprintf("could not save chunk (%d, %d)", x, z), err)
+		return
+	}
+	return
+}
+
+// Marks chunk as needing a save.  dirty is only touched under chunkLock, since
+// Flush clears it from another goroutine.
+func (world *World) markChunkDirty(chunk *Chunk) {
+	world.chunkLock.Lock()
+	chunk.dirty = true
+	world.chunkLock.Unlock()
 }
 
 func (world *World) verifyFormat() (err os.Error) {
@@ -151,6 +369,10 @@ func (world *World) verifyFormat() (err os.Error) {
 				hasSessionLock = true
 			}
 		}
+		// we'd see none of its chunks, and a Generator would happily replace them all.
+		if f.IsDirectory() && f.Name == regiondir {
+			return error.NewError("world is in a region format; convert it to Alpha first", nil)
+		}
 	}
 
 	if !hasLevelDat {
@@ -165,92 +387,205 @@ func (world *World) verifyFormat() (err os.Error) {
 }
 
 func (world *World) lock() (err os.Error) {
-	if world.lockfd != nil {
-		panic("lock fd already exists... should never happen")
-	}
-	sessionLockPath := path.Join(world.dir, sessionlock)
-	world.lockfd, err = os.Open(sessionLockPath, os.O_RDWR|os.O_ASYNC, 0000)
-	if err != nil {
-		error.NewError(fmt.Sprint("could not open ", sessionlock), nil)
-	}
-	// minecraft's locking mechanism is peculiar.
-	// It writes the current system time in milliseconds since 1970 to the file.
-	// It then watches the file for changes.  If a change is monitored, it aborts.
-
-	// This has strange implications, such as the LAST process to open the world owns it,
-	// not the first.
+	world.session, err = world.storage.Lock()
+	return
+}
 
-	// but hey, when in rome...
-	sec, nsec, err := os.Time()
-	if err != nil {
-		err = error.NewError("couldn't get the current time..?!", err)
+// Multiplayer worlds keep each player in players/; single-player worlds don't have it yet.
+func (world *World) makePlayersDir() (err os.Error) {
+	dir := path.Join(world.dir, playersdir)
+	if exists(dir) {
 		return
 	}
+	return os.Mkdir(dir, 0755)
+}
 
-	world.lockmsec = (sec * 1000) + (nsec / 1000000)
-	err = nbt.WriteInt64(world.lockfd, world.lockmsec)
-	if err != nil {
-		err = error.NewError("could not write timestamp to session lock", err)
-		return
+// Returns the world's directory, for the few things that only make sense for a
+// world kept in one.
+func (world *World) directory() (dir string, err os.Error) {
+	if world.dir == "" {
+		err = error.NewError("world isn't kept in a directory", nil)
 	}
-	return
+	return world.dir, err
+}
+
+// Everything that writes to the world checks with this first.
+func (world *World) verifyWritable() os.Error {
+	if world.readOnly {
+		return error.NewError("world was opened read-only", nil)
+	}
+	return world.verifyLock()
 }
 
 func (world *World) verifyLock() (err os.Error) {
-	_, err = world.lockfd.Seek(0, 0)
-	if err != nil {
-		err = error.NewError("could not seek to beginning of session lock", err)
-		return
+	if world.session == nil {
+		return // there's no lock to lose.
+	}
+	world.lockCheck.Lock()
+	defer world.lockCheck.Unlock()
+	if world.closed {
+		return error.NewError("world has been closed", nil)
 	}
-	msec, err := nbt.ReadInt64(world.lockfd)
+	select {
+	case <-world.lockLost:
+		// once it's gone, it's gone, even if the other process puts the timestamp back.
+		return error.NewError("someone else has opened this world :(", nil)
+	default:
+	}
+	held, err := world.session.Held()
 	if err != nil {
-		err = error.NewError("could not read timestamp from session lock", err)
+		err = error.NewError("could not check session lock", err)
 		return
 	}
-	if msec != world.lockmsec {
+	if !held {
+		close(world.lockLost)
 		err = error.NewError("someone else has opened this world :(", nil)
 		return
 	}
 	return
 }
 
+// How often (in ns) the session lock is checked when nothing else is touching the world.
+const lockCheckInterval = 1e9
+
+// Returns a channel that is closed the moment another process opens this world.
+// From then on, the world refuses to write anything, so the only sensible thing
+// left to do is Close it.
+func (world *World) LockLost() <-chan bool {
+	return world.lockLost
+}
+
+// Minecraft notices another process taking the world right away; we'd otherwise
+// only notice the next time we touched the disk.
+func (world *World) watchLock(interval int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// a failed read isn't proof anyone else has the world; keep watching.
+			world.verifyLock()
+		case <-world.lockLost:
+			return
+		case <-world.lockQuit:
+			return
+		}
+	}
+}
+
+// Callers hold lockCheck.
 func (world *World) unlock() os.Error {
-	return world.lockfd.Close()
+	return world.session.Release()
 }
 
-func (world *World) loadLevelDat(level map[string]interface{}) {
-	data := level["Data"].(map[string]interface{})
-	world.Data = Data{
-		SnowCovered: data["SnowCovered"].(int8),
-		Time:        data["Time"].(int64),
-		SpawnX:      data["SpawnX"].(int32),
-		SpawnY:      data["SpawnY"].(int32),
-		SpawnZ:      data["SpawnZ"].(int32),
-		LastPlayed:  data["LastPlayed"].(int64),
-		SizeOnDisk:  data["SizeOnDisk"].(int64),
-		RandomSeed:  data["RandomSeed"].(int64),
+func (world *World) loadLevelDat(level map[string]interface{}) (err os.Error) {
+	r := newCompoundReader(level)
+	data := r.compound("Data")
+	world.levelExtra = r.extra()
+	if r.err != nil {
+		return r.err
 	}
+	world.Data, err = toData(data)
+	return
 }
-func posmod64(i int32) int32 {
-	if i < 0 {
-		i = 64 - i
+
+func (world *World) saveLevelDat() map[string]interface{} {
+	level := newCompound(world.levelExtra)
+	level["Data"] = world.Data.toNBT()
+	return level
+}
+
+func toData(payload map[string]interface{}) (data Data, err os.Error) {
+	r := newCompoundReader(payload)
+	data = Data{
+		Time:       r.int64("Time"),
+		SpawnX:     r.int32("SpawnX"),
+		SpawnY:     r.int32("SpawnY"),
+		SpawnZ:     r.int32("SpawnZ"),
+		LastPlayed: r.int64("LastPlayed"),
+		SizeOnDisk: r.int64("SizeOnDisk"),
+		RandomSeed: r.int64("RandomSeed"),
+	}
+	if r.has("SnowCovered") {
+		snow := r.int8("SnowCovered")
+		data.SnowCovered = &snow
+	}
+	if r.has("version") {
+		version := r.int32("version")
+		data.Version = &version
+	}
+	if r.has("LevelName") {
+		name := r.string("LevelName")
+		data.LevelName = &name
 	}
-	return i % 64
+	if r.has("Player") {
+		if data.Player, err = toPlayer(r.compound("Player")); err != nil {
+			err = error.NewError("could not understand player", err)
+			return
+		}
+	}
+	data.Extra = r.extra()
+	err = r.err
+	return
 }
 
-func (world *World) LoadChunk(x int32, z int32) (err os.Error) {
-	if err = world.verifyLock(); err != nil {
-		return
+func (data *Data) toNBT() map[string]interface{} {
+	c := newCompound(data.Extra)
+	c["Time"] = data.Time
+	c["SpawnX"] = data.SpawnX
+	c["SpawnY"] = data.SpawnY
+	c["SpawnZ"] = data.SpawnZ
+	c["LastPlayed"] = data.LastPlayed
+	c["SizeOnDisk"] = data.SizeOnDisk
+	c["RandomSeed"] = data.RandomSeed
+	if data.SnowCovered != nil {
+		c["SnowCovered"] = *data.SnowCovered
+	}
+	if data.Version != nil {
+		c["version"] = *data.Version
 	}
+	if data.LevelName != nil {
+		c["LevelName"] = *data.LevelName
+	}
+	if data.Player != nil {
+		c["Player"] = data.Player.toNBT()
+	}
+	return c
+}
 
-	xz := MakeXZ(x, z)
-	if _, ok := world.Chunks[xz]; ok {
-		return // nothing to do
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	if pe, ok := err.(*os.PathError); ok && pe.Error == os.ENOENT {
+		return false
 	}
-	var px, pz = posmod64(x), posmod64(z)
+	return true
+}
+
+func posmod64(i int32) int32 {
+	return (i%64 + 64) % 64
+}
 
-	chunkPath := path.Join(
-		world.dir,
+// Loads the chunk at (x, z) into Chunks, blocking until it is available.
+func (world *World) LoadChunk(x int32, z int32) (err os.Error) {
+	_, err = world.LoadChunkAsync(x, z).Wait()
+	return
+}
+
+// Returns the chunk at (x, z) if it has been loaded, or nil if it hasn't.
+func (world *World) Chunk(x int32, z int32) *Chunk {
+	world.chunkLock.Lock()
+	defer world.chunkLock.Unlock()
+	return world.Chunks[MakeXZ(x, z)]
+}
+
+func (world *World) chunkPath(x int32, z int32) string {
+	return alphaChunkPath(world.dir, x, z)
+}
+
+func alphaChunkPath(dir string, x int32, z int32) string {
+	var px, pz = posmod64(x), posmod64(z)
+	return path.Join(
+		dir,
 		int32ToBase36String(px),
 		int32ToBase36String(pz),
 		fmt.Sprint(
@@ -259,85 +594,209 @@ func (world *World) LoadChunk(x int32, z int32) (err os.Error) {
 			".",
 			int32ToBase36String(z),
 			".dat"))
+}
 
-	_, chunkmap, err := nbt.Load(chunkPath)
-	if err != nil {
-		err = error.NewError(fmt.Sprintf("could not load chunk (%d, %d)", x, z), err)
-		return
+// Returns the coordinates of every chunk saved in the world.
+func (world *World) ChunkCoords() []XZ {
+	return world.storage.ChunkCoords()
+}
+
+// Walks the same base36 directory tree chunkPath builds, so anything that isn't
+// where Minecraft would look for it is skipped, as are directories that can't be read.
+func alphaChunkCoords(dir string) (coords []XZ) {
+	for _, xdir := range chunkDirs(dir) {
+		for _, zdir := range chunkDirs(path.Join(dir, xdir.Name)) {
+			files, err := ioutil.ReadDir(path.Join(dir, xdir.Name, zdir.Name))
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				x, z, ok := parseChunkName(f.Name)
+				if ok && f.IsRegular() &&
+					int32ToBase36String(posmod64(x)) == xdir.Name &&
+					int32ToBase36String(posmod64(z)) == zdir.Name {
+					coords = append(coords, MakeXZ(x, z))
+				}
+			}
+		}
 	}
-	world.Chunks[xz] = toChunk(chunkmap)
 	return
+}
 
+// Returns how many bytes the chunk at (x, z) takes up in storage.
+func (world *World) ChunkSize(x int32, z int32) (size int64, err os.Error) {
+	if size, err = world.storage.ChunkSize(x, z); err != nil {
+		err = error.NewError(fmt.Sprintf("could not stat chunk (%d, %d)", x, z), err)
+		return
+	}
+	return
 }
 
-func toChunk(payload map[string]interface{}) *Chunk {
+// Returns the LastUpdate of the chunk at (x, z) without building the whole chunk,
+// so callers can tell whether it has changed before doing anything expensive with it.
+func (world *World) ChunkLastUpdate(x int32, z int32) (lastUpdate int64, err os.Error) {
+	if chunk := world.Chunk(x, z); chunk != nil {
+		return chunk.Level.LastUpdate, nil
+	}
+	b, err := world.storage.Chunk(x, z)
+	if err == nil && b == nil {
+		err = error.NewError("chunk is missing", nil)
+	}
+	var chunkmap map[string]interface{}
+	if err == nil {
+		_, chunkmap, err = decodeNBT(b)
+	}
+	if err != nil {
+		err = error.NewError(fmt.Sprintf("could not read chunk (%d, %d)", x, z), err)
+		return
+	}
+	r := newCompoundReader(chunkmap)
+	levmap := r.compound("Level")
+	if r.err == nil && levmap == nil {
+		r.err = error.NewError("chunk has no Level", nil)
+	}
+	if r.err == nil {
+		lr := newCompoundReader(levmap)
+		lastUpdate, r.err = lr.int64("LastUpdate"), lr.err
+	}
+	if r.err != nil {
+		err = error.NewError(fmt.Sprintf("could not understand chunk (%d, %d)", x, z), r.err)
+		return
+	}
+	return
+}
 
-	levmap := payload["Level"].(map[string]interface{})
-	return &Chunk{
-		Level: Level{
-			Blocks:           levmap["Blocks"].([]byte),
-			Data:             levmap["Data"].([]byte),
-			SkyLight:         levmap["SkyLight"].([]byte),
-			HeightMap:        levmap["HeightMap"].([]byte),
-			BlockLight:       levmap["BlockLight"].([]byte),
-			Entities:         toEntityList(levmap["Entities"].([]interface{})),
-			TileEntities:     levmap["TileEntities"].(interface{}),
-			LastUpdate:       levmap["LastUpdate"].(int64),
-			XPos:             levmap["xPos"].(int32),
-			ZPos:             levmap["xPos"].(int32),
-			TerrainPopulated: levmap["TerrainPopulated"].(int8),
-		},
+// Returns the subdirectories of dir that are named like a chunk directory (0 through 1r).
+func chunkDirs(dir string) (dirs []*os.FileInfo) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if !f.IsDirectory() {
+			continue
+		}
+		if i, err := base36StringToInt32(f.Name); err == nil && i >= 0 && i < 64 && int32ToBase36String(i) == f.Name {
+			dirs = append(dirs, f)
+		}
 	}
+	return
 }
-func toEntityList(payload []interface{}) []*Entity {
-	entities := make([]*Entity, len(payload))
-	for i, e := range payload {
-		entities[i] = toEntity(e.(map[string]interface{}))
+
+// Parses c.<x>.<z>.dat
+func parseChunkName(name string) (x int32, z int32, ok bool) {
+	if !strings.HasPrefix(name, "c.") || !strings.HasSuffix(name, ".dat") {
+		return
+	}
+	xz := name[2 : len(name)-4]
+	dot := strings.Index(xz, ".")
+	if dot < 0 {
+		return
+	}
+	var err os.Error
+	if x, err = base36StringToInt32(xz[:dot]); err != nil {
+		return
 	}
-	return entities
+	if z, err = base36StringToInt32(xz[dot+1:]); err != nil {
+		return
+	}
+	ok = true
+	return
 }
 
-func toEntity(payload map[string]interface{}) *Entity {
-	xyz := payload["Pos"].([]interface{})       // FIXME
-	dxdydz := payload["Motion"].([]interface{}) // FIXME
-	rpy := payload["Rotation"].([]interface{})  // FIXME
+// Reads a chunk off disk.  Does not touch Chunks, so it is safe to call from any goroutine.
+func (world *World) readChunk(x int32, z int32) (chunk *Chunk, err os.Error) {
+	if err = world.verifyLock(); err != nil {
+		return
+	}
+	b, err := world.storage.Chunk(x, z)
+	if err != nil {
+		err = error.NewError(fmt.Sprintf("could not load chunk (%d, %d)", x, z), err)
+		return
+	}
+	if b == nil && world.Generator != nil {
+		if chunk, err = world.Generator.Generate(x, z); err != nil {
+			err = error.NewError(fmt.Sprintf("could not generate chunk (%d, %d)", x, z), err)
+			return
+		}
+		chunk.Level.LastUpdate = world.Data.Time
+		chunk.dirty = true
+		return
+	}
+	if b == nil {
+		err = error.NewError(fmt.Sprintf("there is no chunk (%d, %d)", x, z), nil)
+		return
+	}
+	_, chunkmap, err := decodeNBT(b)
+	if err != nil {
+		err = error.NewError(fmt.Sprintf("could not load chunk (%d, %d)", x, z), err)
+		return
+	}
+	if chunk, err = toChunk(chunkmap); err != nil {
+		err = error.NewError(fmt.Sprintf("could not understand chunk (%d, %d)", x, z), err)
+		return
+	}
+	if chunk.Level.XPos != x || chunk.Level.ZPos != z {
+		err = error.NewError(fmt.Sprintf("chunk (%d, %d) says it is chunk (%d, %d)", x, z, chunk.Level.XPos, chunk.Level.ZPos), nil)
+		chunk = nil
+		return
+	}
+	return
+}
 
-	ent := Entity{
-		Id:           payload["id"].(string),
-		OnGround:     payload["OnGround"].(int8),
-		Air:          payload["Air"].(int16),
-		Fire:         payload["Fire"].(int16),
-		FallDistance: payload["FallDistance"].(float32),
-		Physics: Physics{
-			Position{xyz[0].(float64), xyz[1].(float64), xyz[2].(float64)},
-			Velocity{dxdydz[0].(float64), dxdydz[1].(float64), dxdydz[2].(float64)},
-			Euler{0, rpy[1].(float32), rpy[0].(float32)},
+func toChunk(payload map[string]interface{}) (chunk *Chunk, err os.Error) {
+	r := newCompoundReader(payload)
+	levmap := r.compound("Level")
+	if r.err != nil {
+		return nil, r.err
+	}
+	if levmap == nil {
+		return nil, error.NewError("chunk has no Level", nil)
+	}
+	lr := newCompoundReader(levmap)
+	chunk = &Chunk{
+		Level: Level{
+			Blocks:           lr.bytes("Blocks"),
+			Data:             lr.bytes("Data"),
+			SkyLight:         lr.bytes("SkyLight"),
+			HeightMap:        lr.bytes("HeightMap"),
+			BlockLight:       lr.bytes("BlockLight"),
+			LastUpdate:       lr.int64("LastUpdate"),
+			XPos:             lr.int32("xPos"),
+			ZPos:             lr.int32("zPos"),
+			TerrainPopulated: lr.int8("TerrainPopulated"),
 		},
 	}
-
-	// nullables
-	ihealth, ok := payload["Health"].(int16)
-	if ok {
-		ent.Health = &ihealth
+	level := &chunk.Level
+	if level.Entities, err = toEntityList(lr.list("Entities")); err != nil {
+		err = error.NewError("could not understand entities", err)
+		return
 	}
-
-	iage, ok := payload["Age"].(int16)
-	if ok {
-		ent.Age = &iage
+	if level.TileEntities, err = toTileEntityList(lr.list("TileEntities")); err != nil {
+		err = error.NewError("could not understand tile entities", err)
+		return
 	}
-
-	itile, ok := payload["Tile"].(int16)
-	if ok {
-		ent.Tile = &itile
+	level.Extra = lr.extra()
+	if err = lr.err; err != nil {
+		return
 	}
+	err = level.checkArrays()
+	return
+}
 
-	iitem, ok := payload["Item"].(map[string]interface{})
-	if ok {
-		ent.Item = &Item{
-			Id:     iitem["id"].(int16),
-			Count:  iitem["Count"].(int8),
-			Damage: iitem["Damage"].(int16),
-		}
-	}
-	return &ent
+func (chunk *Chunk) toNBT() map[string]interface{} {
+	l := &chunk.Level
+	levmap := newCompound(l.Extra)
+	levmap["Blocks"] = l.Blocks
+	levmap["Data"] = l.Data
+	levmap["SkyLight"] = l.SkyLight
+	levmap["HeightMap"] = l.HeightMap
+	levmap["BlockLight"] = l.BlockLight
+	levmap["Entities"] = entityListToNBT(l.Entities)
+	levmap["TileEntities"] = tileEntityListToNBT(l.TileEntities)
+	levmap["LastUpdate"] = l.LastUpdate
+	levmap["xPos"] = l.XPos
+	levmap["zPos"] = l.ZPos
+	levmap["TerrainPopulated"] = l.TerrainPopulated
+	return map[string]interface{}{"Level": levmap}
 }
@@ -0,0 +1,192 @@
+// Chunk by chunk comparison of two copies of a world, typically a backup and the
+// live world, and putting chunks back the way the backup has them.
+
+package diff
+
+import "minecraft/world"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+import "reflect"
+import "sort"
+
+type Change int
+
+const (
+	// in the later world only.
+	Added Change = iota
+	// in the earlier world only.
+	Removed
+	// in both, but different.
+	Changed
+)
+
+func (c Change) String() string {
+	switch c {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprint("Change(", int(c), ")")
+}
+
+type ChunkDiff struct {
+	X, Z   int32
+	Change Change
+	// Blocks whose id or data differ.  Every block counts for added and removed chunks.
+	Blocks int
+	// Tile entities that appeared, disappeared or changed, such as a chest that was
+	// emptied.  Entities aren't compared; mobs wander about too much for it to mean
+	// anything.
+	TileEntities int
+}
+
+type Diff struct {
+	// the chunks that differ.
+	Chunks []ChunkDiff
+	// how many chunks are the same in both.
+	Unchanged int
+}
+
+// Compares every chunk saved in either world, before being the earlier copy.  The
+// chunks that differ come out sorted by position.  Both worlds are only read; opening
+// them with world.OpenReadOnly keeps whoever is playing them undisturbed.
+func Compare(before *world.World, after *world.World) (d *Diff, err os.Error) {
+	inBefore := make(map[world.XZ]bool)
+	for _, xz := range before.ChunkCoords() {
+		inBefore[xz] = true
+	}
+	inAfter := make(map[world.XZ]bool)
+	for _, xz := range after.ChunkCoords() {
+		inAfter[xz] = true
+	}
+
+	d = &Diff{}
+	for xz := range inBefore {
+		if !inAfter[xz] {
+			d.Chunks = append(d.Chunks, ChunkDiff{xz.X(), xz.Z(), Removed, world.ChunkSizeX * world.ChunkSizeY * world.ChunkSizeZ, 0})
+		}
+	}
+	for xz := range inAfter {
+		if !inBefore[xz] {
+			d.Chunks = append(d.Chunks, ChunkDiff{xz.X(), xz.Z(), Added, world.ChunkSizeX * world.ChunkSizeY * world.ChunkSizeZ, 0})
+			continue
+		}
+		cd, e := compareChunk(before, after, xz.X(), xz.Z())
+		if e != nil {
+			err = e
+			d = nil
+			return
+		}
+		if cd.Blocks == 0 && cd.TileEntities == 0 {
+			d.Unchanged++
+		} else {
+			d.Chunks = append(d.Chunks, cd)
+		}
+	}
+	sort.Sort(byPosition(d.Chunks))
+	return
+}
+
+type byPosition []ChunkDiff
+
+func (p byPosition) Len() int {
+	return len(p)
+}
+
+func (p byPosition) Less(i int, j int) bool {
+	if p[i].X != p[j].X {
+		return p[i].X < p[j].X
+	}
+	return p[i].Z < p[j].Z
+}
+
+func (p byPosition) Swap(i int, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// Loads a chunk, and drops it again once it has been looked at; we may be reading
+// every chunk in the world.
+func loadOnce(w *world.World, x int32, z int32) (level *world.Level, err os.Error) {
+	chunk, err := w.LoadChunkAsync(x, z).Wait()
+	if err != nil {
+		return
+	}
+	w.UnloadChunk(x, z)
+	return &chunk.Level, nil
+}
+
+func compareChunk(before *world.World, after *world.World, x int32, z int32) (cd ChunkDiff, err os.Error) {
+	cd = ChunkDiff{X: x, Z: z, Change: Changed}
+	a, err := loadOnce(before, x, z)
+	if err != nil {
+		return
+	}
+	b, err := loadOnce(after, x, z)
+	if err != nil {
+		return
+	}
+	for i := range a.Blocks {
+		// data is packed two blocks to a byte, low nibble first.
+		shift := uint(i&1) * 4
+		if a.Blocks[i] != b.Blocks[i] || (a.Data[i/2]>>shift)&15 != (b.Data[i/2]>>shift)&15 {
+			cd.Blocks++
+		}
+	}
+	cd.TileEntities = compareTileEntities(a.TileEntities, b.TileEntities)
+	return
+}
+
+func compareTileEntities(a []world.TileEntity, b []world.TileEntity) (n int) {
+	// positions can't be map keys, so key them by a string instead.
+	key := func(te world.TileEntity) string {
+		base := te.Base()
+		return fmt.Sprint(base.X, ",", base.Y, ",", base.Z)
+	}
+	before := make(map[string]world.TileEntity)
+	for _, te := range a {
+		before[key(te)] = te
+	}
+	for _, te := range b {
+		k := key(te)
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old.ToNBT(), te.ToNBT()) {
+			n++
+		}
+		before[k] = nil, false
+	}
+	return n + len(before)
+}
+
+// Puts the given chunks in live back the way they are in backup: changed chunks are
+// copied from the backup, and chunks the backup doesn't have are deleted.  live must
+// be open for writing, and backup is best opened read-only.  Copied chunks are saved
+// by live's next Flush; deletions happen straight away.
+func Rollback(live *world.World, backup *world.World, chunks []world.XZ) (err os.Error) {
+	inBackup := make(map[world.XZ]bool)
+	for _, xz := range backup.ChunkCoords() {
+		inBackup[xz] = true
+	}
+	for _, xz := range chunks {
+		x, z := xz.X(), xz.Z()
+		if !inBackup[xz] {
+			if err = live.RemoveChunk(x, z); err != nil {
+				return
+			}
+			continue
+		}
+		chunk, e := backup.LoadChunkAsync(x, z).Wait()
+		if e != nil {
+			return error.NewError(fmt.Sprintf("could not load chunk (%d, %d) from the backup", x, z), e)
+		}
+		// it's about to belong to live; the backup mustn't hang on to it.
+		backup.UnloadChunk(x, z)
+		if err = live.PutChunk(chunk); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package diff
+
+import "minecraft/world"
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "path"
+import "testing"
+
+func TestCompareRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	live, err := world.Create(path.Join(dir, "live"), &world.CreateOptions{Seed: 9, Pregenerate: true, PregenerateRadius: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	if err = live.Snapshot(path.Join(dir, "backup")); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := world.OpenReadOnly(path.Join(dir, "backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	// grief (1, 0), and wander off into a new chunk.
+	for y := int32(120); y < 123; y++ {
+		if err = live.SetBlock(16, y, 0, registry.TNT, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = live.LoadChunk(5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err = live.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Compare(backup, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(map[world.XZ]ChunkDiff)
+	for _, cd := range d.Chunks {
+		changed[world.MakeXZ(cd.X, cd.Z)] = cd
+	}
+	if cd, ok := changed[world.MakeXZ(1, 0)]; !ok || cd.Change != Changed || cd.Blocks != 3 {
+		t.Error("expected 3 blocks changed in (1, 0), got ", cd)
+	}
+	if cd, ok := changed[world.MakeXZ(5, 5)]; !ok || cd.Change != Added {
+		t.Error("expected (5, 5) to be added, got ", cd)
+	}
+	if len(d.Chunks) != 2 || d.Unchanged != 4 {
+		t.Errorf("expected 2 different and 4 unchanged chunks, got %d and %d", len(d.Chunks), d.Unchanged)
+	}
+
+	if err = Rollback(live, backup, []world.XZ{world.MakeXZ(1, 0), world.MakeXZ(5, 5)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = live.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = Compare(backup, live); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Chunks) != 0 {
+		t.Error("expected no differences after rolling back, got ", d.Chunks)
+	}
+}
@@ -0,0 +1,266 @@
+// Bulk editing of a world's blocks, with undo.  Everything goes through
+// World.SetBlock, so changed chunks are marked dirty and relit; Flush the world to
+// save them.  Entities and tile entities are left alone; use schematics to move
+// those.
+
+package edit
+
+import "minecraft/world"
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// How many operations an Editor remembers for Undo.
+const maxUndo = 32
+
+type Block struct {
+	Id, Data byte
+}
+
+var Air = Block{registry.Air, 0}
+
+// A box of blocks, corners included.
+type Selection struct {
+	Min, Max world.BlockPos
+}
+
+// Returns the selection between the corners a and b, which can be given in any order.
+func Select(a world.BlockPos, b world.BlockPos) Selection {
+	s := Selection{a, b}
+	if s.Min.X > s.Max.X {
+		s.Min.X, s.Max.X = s.Max.X, s.Min.X
+	}
+	if s.Min.Y > s.Max.Y {
+		s.Min.Y, s.Max.Y = s.Max.Y, s.Min.Y
+	}
+	if s.Min.Z > s.Max.Z {
+		s.Min.Z, s.Max.Z = s.Max.Z, s.Min.Z
+	}
+	return s
+}
+
+func (s Selection) Size() (width int, height int, length int) {
+	return int(s.Max.X-s.Min.X) + 1, int(s.Max.Y-s.Min.Y) + 1, int(s.Max.Z-s.Min.Z) + 1
+}
+
+func (s Selection) Contains(p world.BlockPos) bool {
+	return p.X >= s.Min.X && p.X <= s.Max.X &&
+		p.Y >= s.Min.Y && p.Y <= s.Max.Y &&
+		p.Z >= s.Min.Z && p.Z <= s.Max.Z
+}
+
+// Calls f for every block in the selection, bottom layer first, stopping at the
+// first error.
+func (s Selection) each(f func(p world.BlockPos) os.Error) os.Error {
+	for y := s.Min.Y; y <= s.Max.Y; y++ {
+		for z := s.Min.Z; z <= s.Max.Z; z++ {
+			for x := s.Min.X; x <= s.Max.X; x++ {
+				if err := f(world.BlockPos{x, y, z}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Blocks copied out of a world, ready to be pasted somewhere else.
+type Clipboard struct {
+	Width, Height, Length int
+	// indexed by (y*Length + z)*Width + x, like a schematic.
+	Blocks []Block
+}
+
+func newClipboard(width int, height int, length int) *Clipboard {
+	return &Clipboard{width, height, length, make([]Block, width*height*length)}
+}
+
+func (c *Clipboard) index(x int, y int, z int) int {
+	return (y*c.Length+z)*c.Width + x
+}
+
+// Returns the clipboard turned a quarter turn clockwise, looking down, turns times.
+// Block data isn't changed, so stairs, torches and the like keep facing the way
+// they did.
+func (c *Clipboard) Rotate(turns int) *Clipboard {
+	turns = (turns%4 + 4) % 4
+	for ; turns > 0; turns-- {
+		r := newClipboard(c.Length, c.Height, c.Width)
+		for y := 0; y < c.Height; y++ {
+			for z := 0; z < c.Length; z++ {
+				for x := 0; x < c.Width; x++ {
+					r.Blocks[r.index(c.Length-1-z, y, x)] = c.Blocks[c.index(x, y, z)]
+				}
+			}
+		}
+		c = r
+	}
+	return c
+}
+
+// What a block was before an operation changed it.
+type change struct {
+	pos world.BlockPos
+	old Block
+}
+
+// Edits a world, remembering what each operation changed so it can be undone.
+// Like World.SetBlock, not safe to use from several goroutines at once.
+type Editor struct {
+	w       *world.World
+	history [][]change
+}
+
+func NewEditor(w *world.World) *Editor {
+	return &Editor{w: w}
+}
+
+// Runs one operation.  f sets blocks with set, which journals whatever was there
+// before.  Returns how many blocks actually changed.  A failed operation is still
+// journalled, so the part of it that happened can be undone.
+func (e *Editor) do(f func(set func(p world.BlockPos, b Block) os.Error) os.Error) (n int, err os.Error) {
+	var journal []change
+	set := func(p world.BlockPos, b Block) os.Error {
+		id, data, err := e.w.Block(p.X, p.Y, p.Z)
+		if err != nil {
+			return err
+		}
+		if id == b.Id && data == b.Data {
+			return nil
+		}
+		if err = e.w.SetBlock(p.X, p.Y, p.Z, b.Id, b.Data); err != nil {
+			return err
+		}
+		journal = append(journal, change{p, Block{id, data}})
+		return nil
+	}
+	err = f(set)
+	if len(journal) > 0 {
+		e.history = append(e.history, journal)
+		if len(e.history) > maxUndo {
+			e.history = e.history[len(e.history)-maxUndo:]
+		}
+	}
+	return len(journal), err
+}
+
+// Sets every block in the selection to b.
+func (e *Editor) Fill(s Selection, b Block) (n int, err os.Error) {
+	return e.do(func(set func(world.BlockPos, Block) os.Error) os.Error {
+		return s.each(func(p world.BlockPos) os.Error {
+			return set(p, b)
+		})
+	})
+}
+
+// Changes every block in the selection with id from into to, whatever its data.
+func (e *Editor) Replace(s Selection, from byte, to Block) (n int, err os.Error) {
+	return e.do(func(set func(world.BlockPos, Block) os.Error) os.Error {
+		return s.each(func(p world.BlockPos) os.Error {
+			id, _, err := e.w.Block(p.X, p.Y, p.Z)
+			if err != nil || id != from {
+				return err
+			}
+			return set(p, to)
+		})
+	})
+}
+
+// Copies the blocks in the selection.  Nothing is changed, so there's nothing to undo.
+func (e *Editor) Copy(s Selection) (c *Clipboard, err os.Error) {
+	w, h, l := s.Size()
+	c = newClipboard(w, h, l)
+	err = s.each(func(p world.BlockPos) os.Error {
+		id, data, err := e.w.Block(p.X, p.Y, p.Z)
+		c.Blocks[c.index(int(p.X-s.Min.X), int(p.Y-s.Min.Y), int(p.Z-s.Min.Z))] = Block{id, data}
+		return err
+	})
+	if err != nil {
+		c = nil
+	}
+	return
+}
+
+// Writes the clipboard into the world with its lowest corner at origin.  If skipAir
+// is set, air in the clipboard leaves what's already in the world alone.
+func (e *Editor) Paste(c *Clipboard, origin world.BlockPos, skipAir bool) (n int, err os.Error) {
+	if len(c.Blocks) != c.Width*c.Height*c.Length {
+		return 0, error.NewError(fmt.Sprintf("a %dx%dx%d clipboard should have %d blocks, got %d",
+			c.Width, c.Height, c.Length, c.Width*c.Height*c.Length, len(c.Blocks)), nil)
+	}
+	return e.do(func(set func(world.BlockPos, Block) os.Error) os.Error {
+		for y := 0; y < c.Height; y++ {
+			for z := 0; z < c.Length; z++ {
+				for x := 0; x < c.Width; x++ {
+					b := c.Blocks[c.index(x, y, z)]
+					if skipAir && b.Id == Air.Id {
+						continue
+					}
+					p := world.BlockPos{origin.X + int32(x), origin.Y + int32(y), origin.Z + int32(z)}
+					if err := set(p, b); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Turns the selection a quarter turn clockwise, looking down, turns times, keeping
+// its lowest corner where it is.  The blocks it leaves behind become air.  Returns
+// where the selection ended up.
+func (e *Editor) Rotate(s Selection, turns int) (rotated Selection, n int, err os.Error) {
+	c, err := e.Copy(s)
+	if err != nil {
+		return
+	}
+	c = c.Rotate(turns)
+	rotated = Selection{s.Min, world.BlockPos{
+		s.Min.X + int32(c.Width) - 1, s.Max.Y, s.Min.Z + int32(c.Length) - 1,
+	}}
+	// one operation, so one Undo puts it all back.
+	n, err = e.do(func(set func(world.BlockPos, Block) os.Error) os.Error {
+		err := s.each(func(p world.BlockPos) os.Error {
+			if rotated.Contains(p) {
+				return nil
+			}
+			return set(p, Air)
+		})
+		if err != nil {
+			return err
+		}
+		return rotated.each(func(p world.BlockPos) os.Error {
+			return set(p, c.Blocks[c.index(int(p.X-s.Min.X), int(p.Y-s.Min.Y), int(p.Z-s.Min.Z))])
+		})
+	})
+	return
+}
+
+// Puts back the blocks the most recent operation changed.  Returns how many were
+// put back; 0 if there is nothing left to undo.
+func (e *Editor) Undo() (n int, err os.Error) {
+	if len(e.history) == 0 {
+		return
+	}
+	journal := e.history[len(e.history)-1]
+	e.history = e.history[:len(e.history)-1]
+	// backwards, in case an operation touched a block twice.
+	for i := len(journal) - 1; i >= 0; i-- {
+		c := journal[i]
+		if err = e.w.SetBlock(c.pos.X, c.pos.Y, c.pos.Z, c.old.Id, c.old.Data); err != nil {
+			// keep what's left, so it can be tried again.
+			e.history = append(e.history, journal[:i+1])
+			return
+		}
+		n++
+	}
+	return
+}
+
+// How many operations can be undone.
+func (e *Editor) UndoLen() int {
+	return len(e.history)
+}
@@ -0,0 +1,106 @@
+package edit
+
+import "minecraft/world"
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "testing"
+
+func tempWorld(t *testing.T) (w *world.World, dir string) {
+	dir, err := ioutil.TempDir("", "edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, err = world.Create(dir, &world.CreateOptions{Seed: 3}); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return
+}
+
+func expectBlock(t *testing.T, w *world.World, x int32, y int32, z int32, id byte) {
+	got, _, err := w.Block(x, y, z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("expected block %d at (%d, %d, %d), got %d", id, x, y, z, got)
+	}
+}
+
+func TestFillReplaceUndo(t *testing.T) {
+	w, dir := tempWorld(t)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+	e := NewEditor(w)
+
+	// high enough up to be all air, and across a chunk border.
+	s := Select(world.BlockPos{17, 112, 2}, world.BlockPos{14, 110, 0})
+	if n, err := e.Fill(s, Block{registry.Stone, 0}); err != nil || n != 36 {
+		t.Fatalf("expected to fill 36 blocks, filled %d (%v)", n, err)
+	}
+	if n, err := e.Replace(Select(world.BlockPos{14, 110, 0}, world.BlockPos{20, 110, 0}), registry.Stone, Block{registry.Planks, 0}); err != nil || n != 4 {
+		t.Fatalf("expected to replace 4 blocks, replaced %d (%v)", n, err)
+	}
+	expectBlock(t, w, 17, 110, 0, registry.Planks)
+	expectBlock(t, w, 17, 111, 0, registry.Stone)
+
+	if n, err := e.Undo(); err != nil || n != 4 {
+		t.Fatalf("expected to undo 4 blocks, undid %d (%v)", n, err)
+	}
+	expectBlock(t, w, 17, 110, 0, registry.Stone)
+	if n, err := e.Undo(); err != nil || n != 36 {
+		t.Fatalf("expected to undo 36 blocks, undid %d (%v)", n, err)
+	}
+	expectBlock(t, w, 15, 111, 1, registry.Air)
+	if n, _ := e.Undo(); n != 0 || e.UndoLen() != 0 {
+		t.Error("expected nothing left to undo")
+	}
+}
+
+func TestCopyRotatePaste(t *testing.T) {
+	w, dir := tempWorld(t)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+	e := NewEditor(w)
+
+	// a 3 long, 1 wide line along x, cobblestone at the low end.
+	line := Select(world.BlockPos{0, 110, 0}, world.BlockPos{2, 110, 0})
+	e.Fill(line, Block{registry.Stone, 0})
+	w.SetBlock(0, 110, 0, registry.Cobblestone, 0)
+
+	c, err := e.Copy(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := c.Rotate(1)
+	if r.Width != 1 || r.Length != 3 {
+		t.Fatalf("expected a 1x3 clipboard after turning, got %dx%d", r.Width, r.Length)
+	}
+	if r.Blocks[r.index(0, 0, 0)].Id != registry.Cobblestone {
+		t.Error("expected the low end to stay at the low end after a clockwise turn")
+	}
+	if back := r.Rotate(3); back.Width != 3 || back.Blocks[back.index(0, 0, 0)].Id != registry.Cobblestone {
+		t.Error("expected four turns to come back where they started")
+	}
+
+	if _, err = e.Paste(r, world.BlockPos{-5, 110, -5}, true); err != nil {
+		t.Fatal(err)
+	}
+	expectBlock(t, w, -5, 110, -5, registry.Cobblestone)
+	expectBlock(t, w, -5, 110, -3, registry.Stone)
+
+	rotated, _, err := e.Rotate(line, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Max.X != 0 || rotated.Max.Z != 2 {
+		t.Errorf("expected the line to end up along z, got %v", rotated)
+	}
+	expectBlock(t, w, 0, 110, 2, registry.Stone)
+	expectBlock(t, w, 2, 110, 0, registry.Air)
+	e.Undo()
+	expectBlock(t, w, 2, 110, 0, registry.Stone)
+	expectBlock(t, w, 0, 110, 2, registry.Air)
+}
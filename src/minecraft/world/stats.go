@@ -0,0 +1,161 @@
+// Aggregate statistics about everything in a world.
+
+package stats
+
+import "minecraft/world"
+import "minecraft/registry"
+import "minecraft/error"
+
+import "fmt"
+import "os"
+
+// Ores are counted per height, since how deep they are is what matters.
+var oreNames = map[byte]string{
+	registry.GoldOre:            "gold",
+	registry.IronOre:            "iron",
+	registry.CoalOre:            "coal",
+	registry.DiamondOre:         "diamond",
+	registry.RedstoneOre:        "redstone",
+	registry.GlowingRedstoneOre: "redstone", // because someone walked past it
+}
+
+type Stats struct {
+	Chunks int
+	// compressed, on disk.
+	Bytes, SmallestChunk, LargestChunk int64
+	// how many of each block id there are.
+	Blocks [256]int64
+	// ore name -> how many at each y.
+	Ores         map[string][]int64
+	Entities     map[string]int
+	TileEntities map[string]int
+	// chunks that couldn't be read.
+	Failed []world.XZ
+}
+
+type Progress struct {
+	Done, Total int
+}
+
+func newStats() *Stats {
+	s := &Stats{
+		Ores:         make(map[string][]int64),
+		Entities:     make(map[string]int),
+		TileEntities: make(map[string]int),
+	}
+	for _, name := range oreNames {
+		s.Ores[name] = make([]int64, world.ChunkSizeY)
+	}
+	return s
+}
+
+func (s *Stats) addChunk(level *world.Level, size int64) {
+	s.Chunks++
+	s.Bytes += size
+	if s.Chunks == 1 || size < s.SmallestChunk {
+		s.SmallestChunk = size
+	}
+	if size > s.LargestChunk {
+		s.LargestChunk = size
+	}
+	for i, id := range level.Blocks {
+		s.Blocks[id]++
+		if name, ok := oreNames[id]; ok {
+			s.Ores[name][i%world.ChunkSizeY]++
+		}
+	}
+	for _, e := range level.Entities {
+		s.Entities[e.Id()]++
+	}
+	for _, te := range level.TileEntities {
+		s.TileEntities[te.Id()]++
+	}
+}
+
+func (s *Stats) merge(o *Stats) {
+	if o.Chunks > 0 && (s.Chunks == 0 || o.SmallestChunk < s.SmallestChunk) {
+		s.SmallestChunk = o.SmallestChunk
+	}
+	if o.LargestChunk > s.LargestChunk {
+		s.LargestChunk = o.LargestChunk
+	}
+	s.Chunks += o.Chunks
+	s.Bytes += o.Bytes
+	for id, n := range o.Blocks {
+		s.Blocks[id] += n
+	}
+	for name, counts := range o.Ores {
+		for y, n := range counts {
+			s.Ores[name][y] += n
+		}
+	}
+	for id, n := range o.Entities {
+		s.Entities[id] += n
+	}
+	for id, n := range o.TileEntities {
+		s.TileEntities[id] += n
+	}
+	s.Failed = append(s.Failed, o.Failed...)
+}
+
+// Reads every chunk in the world, using workers goroutines, and adds them up.  If
+// progress isn't nil, it is sent a Progress after every chunk and closed at the
+// end; somebody has to be reading it.  Chunks that can't be read are listed in
+// Failed rather than stopping the scan.
+func Scan(w *world.World, workers int, progress chan<- Progress) (s *Stats, err os.Error) {
+	if workers < 1 {
+		return nil, error.NewError(fmt.Sprint("need at least one worker, got ", workers), nil)
+	}
+	if progress != nil {
+		defer close(progress)
+	}
+	coords := w.ChunkCoords()
+
+	work := make(chan world.XZ)
+	done := make(chan bool)
+	results := make(chan *Stats)
+	for i := 0; i < workers; i++ {
+		go func() {
+			part := newStats()
+			for xz := range work {
+				scanChunk(w, xz, part)
+				done <- true
+			}
+			results <- part
+		}()
+	}
+	go func() {
+		for _, xz := range coords {
+			work <- xz
+		}
+		close(work)
+	}()
+	for i := range coords {
+		<-done
+		if progress != nil {
+			progress <- Progress{i + 1, len(coords)}
+		}
+	}
+	s = newStats()
+	for i := 0; i < workers; i++ {
+		s.merge(<-results)
+	}
+	return
+}
+
+func scanChunk(w *world.World, xz world.XZ, s *Stats) {
+	x, z := xz.X(), xz.Z()
+	chunk, err := w.LoadChunkAsync(x, z).Wait()
+	if err != nil {
+		s.Failed = append(s.Failed, xz)
+		return
+	}
+	size, err := w.ChunkSize(x, z)
+	if err != nil {
+		s.Failed = append(s.Failed, xz)
+		return
+	}
+	s.addChunk(&chunk.Level, size)
+	// we're going to read the whole world; don't keep it all in memory.
+	w.UnloadChunk(x, z)
+}
@@ -0,0 +1,57 @@
+package stats
+
+import "minecraft/world"
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "testing"
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := world.Create(dir, &world.CreateOptions{Seed: 5, Pregenerate: true, PregenerateRadius: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	progress := make(chan Progress)
+	last := make(chan Progress)
+	go func() {
+		var p Progress
+		for p = range progress {
+		}
+		last <- p
+	}()
+	s, err := Scan(w, 3, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := <-last
+
+	// a radius of 2 is 13 chunks: 5 across the middle, 3 either side of that, and 1 beyond.
+	if s.Chunks != 13 || len(s.Failed) != 0 {
+		t.Errorf("expected 13 chunks and no failures, got %d and %d", s.Chunks, len(s.Failed))
+	}
+	if p.Done != 13 || p.Total != 13 {
+		t.Error("expected progress to finish at 13/13, got ", p)
+	}
+	var blocks int64
+	for _, n := range s.Blocks {
+		blocks += n
+	}
+	if blocks != 13*world.ChunkSizeX*world.ChunkSizeY*world.ChunkSizeZ {
+		t.Error("expected every block to be counted once, got ", blocks)
+	}
+	// generated chunks have a bedrock floor.
+	if s.Blocks[registry.Bedrock] != 13*world.ChunkSizeX*world.ChunkSizeZ {
+		t.Error("expected one bedrock per column, got ", s.Blocks[registry.Bedrock])
+	}
+	if s.SmallestChunk <= 0 || s.SmallestChunk > s.LargestChunk {
+		t.Errorf("chunk sizes don't add up: %d to %d", s.SmallestChunk, s.LargestChunk)
+	}
+}
@@ -1,20 +1,379 @@
 package world
 
+import "minecraft/registry"
+
+import "io/ioutil"
+import "os"
+import "path"
+import "reflect"
 import "testing"
+import "time"
 
 func TestWorld(t *testing.T) {
-	w, err := Open("/Users/roberthencke/Downloads/world/")
+	w, err := NewFixture(1).
+		Flat(0, 0, 2, registry.Bedrock, registry.Stone, registry.Dirt, registry.Grass).
+		Block(5, 4, 5, registry.Wool, 14).
+		Open()
 	if err != nil {
+		t.Fatal(err)
+	}
+	w.Generator = nil
+	for _, xz := range w.ChunkCoords() {
+		if err = w.LoadChunk(xz.X(), xz.Z()); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(w.Chunks) != 13 {
+		t.Error("expected 13 chunks, got ", len(w.Chunks))
+	}
+	if id, data, err := w.Block(5, 4, 5); err != nil || id != registry.Wool || data != 14 {
+		t.Errorf("expected red wool, got %d:%d (%v)", id, data, err)
+	}
+	if err = w.LoadChunk(3, 0); err == nil {
+		t.Error("expected a chunk that was never added to fail to load without a generator")
+	}
+
+	// changes survive a flush and reopening the same storage.
+	if err = w.SetBlock(-20, 10, 3, registry.Glass, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
 		t.Error(err)
 	}
-	for i := int32(-128); i < 128; i++ {
-		for j := int32(-128); j < 128; j++ {
-			w.LoadChunk(i, j)
+	w, err = OpenStorage(w.storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if id, _, err := w.Block(-20, 10, 3); err != nil || id != registry.Glass {
+		t.Errorf("expected glass after reopening, got %d (%v)", id, err)
+	}
+	if err = w.Snapshot(path.Join(os.TempDir(), "never")); err == nil {
+		t.Error("expected snapshotting a world in memory to fail")
+	}
+}
+
+func TestLoadChunkAsync(t *testing.T) {
+	w, err := NewFixture(1).Flat(0, 0, 8, registry.Bedrock).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Generator = nil
+
+	a, b := w.LoadChunkAsync(0, 0), w.LoadChunkAsync(0, 0)
+	if a != b {
+		t.Error("concurrent requests for the same chunk should share a future")
+	}
+	chunk, err := a.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Chunk(0, 0) != chunk {
+		t.Error("loaded chunk should be in Chunks")
+	}
+
+	for _, f := range w.Prefetch(0, 0, 8) {
+		<-f.Done()
+	}
+	if w.Chunk(8, 0) == nil && w.Chunk(0, 8) == nil {
+		t.Error("prefetch didn't load anything at the edge of its radius")
+	}
+}
+
+func TestLevelDatRoundTrip(t *testing.T) {
+	level := map[string]interface{}{
+		"Data": map[string]interface{}{
+			"Time":       int64(12000),
+			"LastPlayed": int64(1297000000000),
+			"SpawnX":     int32(10),
+			"SpawnY":     int32(64),
+			"SpawnZ":     int32(-20),
+			"SizeOnDisk": int64(4096),
+			"RandomSeed": int64(-1234567),
+			"version":    int32(19132),
+			"Player": map[string]interface{}{
+				"Pos":          []interface{}{float64(1), float64(65), float64(2)},
+				"Motion":       []interface{}{float64(0), float64(0), float64(0)},
+				"Rotation":     []interface{}{float32(90), float32(10)},
+				"FallDistance": float32(0),
+				"Fire":         int16(-20),
+				"Air":          int16(300),
+				"OnGround":     int8(1),
+				"AttackTime":   int16(0),
+				"DeathTime":    int16(0),
+				"HurtTime":     int16(0),
+				"Health":       int16(20),
+				"Dimension":    int32(0),
+				"Score":        int32(0),
+				"Inventory": []interface{}{
+					map[string]interface{}{"Slot": int8(0), "id": int16(276), "Count": int8(1), "Damage": int16(5)},
+				},
+				"SleepTimer": int16(0),
+			},
+			"thundering": int8(0),
+		},
+		"Unknown": "kept",
+	}
+	w := new(World)
+	if err := w.loadLevelDat(level); err != nil {
+		t.Fatal(err)
+	}
+	if w.Data.Player == nil || w.Data.Player.Health != 20 || len(w.Data.Player.Inventory) != 1 {
+		t.Error("player wasn't understood: ", w.Data.Player)
+	}
+	if saved := w.saveLevelDat(); !reflect.DeepEqual(saved, level) {
+		t.Error("expected ", level, ", got ", saved)
+	}
+}
+
+func TestValidPlayerName(t *testing.T) {
+	for _, name := range []string{"Notch", "a_b_9"} {
+		if !validPlayerName(name) {
+			t.Error("expected ", name, " to be a valid player name")
+		}
+	}
+	for _, name := range []string{"", "../level", "has space", "waytoolongforaplayername"} {
+		if validPlayerName(name) {
+			t.Error("expected ", name, " to be rejected")
 		}
 	}
-	err = w.Close()
+}
+
+func TestPlayerRoundTrip(t *testing.T) {
+	s, err := NewFixture(1).Storage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := (&Player{Health: 15, Inventory: []InventorySlot{{Slot: 3, Item: Item{Id: registry.Torch, Count: 10}}}}).toNBT()
+	saved["SpawnX"] = int32(42) // a newer game's key we don't model
+	saved["Inventory"].([]interface{})[0].(map[string]interface{})["tag"] = map[string]interface{}{"Name": "Lucky"}
+	b, err := encodeNBT("", saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PutPlayer("Notch", b)
+	w, err := OpenStorage(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	p, err := w.LoadPlayer("Notch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.Health != 15 || len(p.Inventory) != 1 || p.Inventory[0].Item.Count != 10 {
+		t.Fatal("player wasn't understood: ", p)
+	}
+	if p.Extra["SpawnX"] != int32(42) {
+		t.Error("expected SpawnX to be kept, got ", p.Extra)
+	}
+	if tag, ok := p.Inventory[0].Extra["tag"].(map[string]interface{}); !ok || tag["Name"] != "Lucky" {
+		t.Error("expected the torch's tag to be kept, got ", p.Inventory[0].Extra)
+	}
+	if err = w.SavePlayer("Notch", p); err != nil {
+		t.Fatal(err)
+	}
+	again, err := w.LoadPlayer("Notch")
 	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, p) {
+		t.Error("expected ", p, ", got ", again)
+	}
+	if p, err = w.LoadPlayer("Nobody"); p != nil || err != nil {
+		t.Errorf("expected no player and no error for a player never saved, got %v (%v)", p, err)
+	}
+}
+
+func TestInventoryMaxStack(t *testing.T) {
+	inv := []InventorySlot{
+		{Slot: 0, Item: Item{Id: registry.IronSword, Count: 5}},
+		{Slot: 1, Item: Item{Id: registry.Torch, Count: 100}},
+		{Slot: 2, Item: Item{Id: registry.Arrow, Count: 30}},
+		{Slot: 3, Item: Item{Id: 9999, Count: 100}},
+	}
+	r := newCompoundReader(map[string]interface{}{"Inventory": inventoryToNBT(inv)})
+	loaded := toInventory(r, "Inventory")
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	for i, count := range []int8{1, 64, 30, 100} {
+		if loaded[i].Item.Count != count {
+			t.Errorf("expected %d in slot %d, got %d", count, i, loaded[i].Item.Count)
+		}
+	}
+}
+
+func TestChunkPosition(t *testing.T) {
+	chunk := &Chunk{Level: newLevel(3, -2)}
+	loaded, err := toChunk(chunk.toNBT())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Level.XPos != 3 || loaded.Level.ZPos != -2 {
+		t.Errorf("expected chunk (3, -2), got (%d, %d)", loaded.Level.XPos, loaded.Level.ZPos)
+	}
+}
+
+// Creates a fresh world in a temporary directory.  Remove the directory when done.
+func tempWorld(t *testing.T, options *CreateOptions) (w *World, dir string) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, err = Create(dir, options); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestCreate(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1234, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	if w.Data.RandomSeed != 1234 {
+		t.Error("expected seed 1234, got ", w.Data.RandomSeed)
+	}
+	if w.Data.SpawnY <= 0 {
+		t.Error("expected spawn to be on the ground, got y=", w.Data.SpawnY)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pregenerated chunks should have been saved, so they load without a generator.
+	w, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Generator = nil
+	if err = w.LoadChunk(1, 0); err != nil {
+		t.Error(err)
+	}
+	if _, err = Create(dir, nil); err == nil {
+		t.Error("expected an error creating a world over an existing one")
+	}
+}
+
+func TestLockLost(t *testing.T) {
+	w, dir := tempWorld(t, nil)
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	other, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	select {
+	case <-w.LockLost():
+	case <-time.After(3 * lockCheckInterval):
+		t.Fatal("didn't notice the world being opened elsewhere")
+	}
+	if err = w.Flush(); err == nil {
+		t.Error("expected Flush to fail once the lock was lost")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	w, dir := tempWorld(t, nil)
+	defer os.RemoveAll(dir)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
 		t.Error(err)
 	}
+	if err := w.Flush(); err == nil {
+		t.Error("expected Flush to fail on a closed world")
+	}
+}
+
+func TestXZ(t *testing.T) {
+	for _, c := range [][2]int32{{0, 0}, {1, -1}, {-1, 1}, {-30000, 29999}, {2147483647, -2147483648}} {
+		xz := MakeXZ(c[0], c[1])
+		if xz.X() != c[0] || xz.Z() != c[1] {
+			t.Errorf("MakeXZ(%d, %d) came back as (%d, %d)", c[0], c[1], xz.X(), xz.Z())
+		}
+	}
+}
+
+func TestBase36RoundTrip(t *testing.T) {
+	for _, i := range []int32{0, 1, 35, 36, -1, -13, 64, 1000000, -2147483647} {
+		s := int32ToBase36String(i)
+		j, err := base36StringToInt32(s)
+		if err != nil || i != j {
+			t.Errorf("%d encoded as %q decoded as %d (%v)", i, s, j, err)
+		}
+	}
+	for _, s := range []string{"", "-", "A", "c.1", "zzzzzzz"} {
+		if _, err := base36StringToInt32(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestChunkCoords(t *testing.T) {
+	w, dir := tempWorld(t, &CreateOptions{Seed: 1, Pregenerate: true, PregenerateRadius: 1})
+	defer os.RemoveAll(dir)
+	defer w.Close()
+
+	found := make(map[XZ]bool)
+	for _, xz := range w.ChunkCoords() {
+		found[xz] = true
+	}
+	// a radius of 1 is the spawn chunk and its four neighbours.
+	expected := [][2]int32{{0, 0}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if len(found) != len(expected) {
+		t.Error("expected 5 pregenerated chunks, got ", len(found))
+	}
+	for _, c := range expected {
+		if !found[MakeXZ(c[0], c[1])] {
+			t.Errorf("chunk (%d, %d) wasn't found", c[0], c[1])
+		}
+	}
+}
+
+func TestChunkLastUpdate(t *testing.T) {
+	chunk := &Chunk{Level: newLevel(2, 3)}
+	chunk.Level.LastUpdate = 1234
+	w, err := NewFixture(1).Chunk(chunk).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Generator = nil
 
+	if lastUpdate, err := w.ChunkLastUpdate(2, 3); err != nil || lastUpdate != 1234 {
+		t.Errorf("expected LastUpdate 1234, got %d (%v)", lastUpdate, err)
+	}
+	if w.Chunk(2, 3) != nil {
+		t.Error("reading LastUpdate shouldn't load the chunk")
+	}
+	if _, err = w.ChunkLastUpdate(5, 5); err == nil {
+		t.Error("expected an error for a chunk that isn't there")
+	}
+}
+
+func TestNegativeChunkPath(t *testing.T) {
+	// Minecraft files negative chunks under the true modulus: -1 goes in 1r (63).
+	for _, c := range []struct {
+		x, z     int32
+		expected string
+	}{
+		{-1, 0, "1r/0/c.-1.0.dat"},
+		{-64, -65, "0/1r/c.-1s.-1t.dat"},
+		{63, 64, "1r/0/c.1r.1s.dat"},
+	} {
+		if p := alphaChunkPath("world", c.x, c.z); p != path.Join("world", c.expected) {
+			t.Errorf("expected chunk (%d, %d) at %s, got %s", c.x, c.z, c.expected, p)
+		}
+	}
 }
@@ -2,6 +2,7 @@ package main
 
 import "minecraft/world"
 
+import "fmt"
 import "net"
 import "os"
 
@@ -10,6 +11,7 @@ type server struct {
 	done      chan bool
 	clientMgr *clientMgr
 	worldMgr  *worldMgr
+	world     *world.World
 	id        string
 	name      string
 	motd      string
@@ -27,6 +29,7 @@ func newServer(address *net.TCPAddr, world *world.World) (s *server, err os.Erro
 	s.done = make(chan bool)
 	s.clientMgr = makeClientMgr()
 	s.worldMgr = makeWorldMgr(world)
+	s.world = world
 	s.id = "bcfd241a420f886e"
 
 	go s.acceptConnections()
@@ -47,6 +50,15 @@ func (s *server) acceptConnections() {
 	}
 }
 
+// Loads the player called name, or makes a new one at a safe spot near spawn if
+// they have never played here before.
+func (s *server) player(name string) (p *world.Player, err os.Error) {
+	if p, err = s.world.LoadPlayer(name); err == nil && p == nil {
+		p, err = s.world.NewPlayer()
+	}
+	return
+}
+
 func (s *server) talk(conn *conn) {
 	<-conn.chandshake
 	conn.shandshake <- &SHandshake{ServerID: s.id}
@@ -55,11 +67,26 @@ func (s *server) talk(conn *conn) {
 	s.clientMgr.addClient <- &addClientReq{&client{cl.Username, conn}, idchan}
 	conn.id = <-idchan
 	conn.slogin <- &SLogin{PlayerID: conn.id, ServerName: s.name, MOTD: s.motd}
+	username := cl.Username
+	player, err := s.player(username)
+	if err != nil {
+		fmt.Printf("can't place %s; err=%s\n", username, err.String())
+		conn.connection.Close()
+		return
+	}
 	cl, idchan = nil, nil
 
-	spawnpos := make(chan *BlockPositionData)
-	s.worldMgr.getSpawnPos <- &spawnPosReq{conn.id, spawnpos}
-	conn.sspawnposition <- &SSpawnPosition{*<-spawnpos}
+	// level.dat's spawn may be inside a wall; tell the client where new players really go.
+	spawn, err := s.world.SafeSpawn()
+	if err != nil {
+		fmt.Printf("can't find a spawn for %s; err=%s\n", username, err.String())
+		conn.connection.Close()
+		return
+	}
+	conn.sspawnposition <- &SSpawnPosition{BlockPositionData{spawn.X, spawn.Y, spawn.Z}}
+	// the server sends eye height first, then feet.
+	pos, look := player.Physics.Position, player.Physics.Euler
+	conn.splayermovelook <- &SPlayerMoveLook{PlayerPositionData{pos.X, pos.Y, pos.Y - world.PlayerEyeHeight, pos.Z}, PlayerLookData{look.Yaw, look.Pitch}, player.OnGround}
 	for {
 		select {
 		case <-conn.ckeepalive: